@@ -0,0 +1,269 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/history"
+	"github.com/bCoder778/qitmeer_test/test"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// default_runs is how many of the latest runs are listed when the request
+// does not say.
+const default_runs = 10
+
+// control_header must be set on the requests that change state. A web page
+// can not set it on a cross origin request without the preflight the api
+// never allows, so pages open in a browser can not start or cancel runs.
+const control_header = "X-Qitmeer-Test"
+
+// The control api is meant for the local machine and should only listen on
+// a loopback address. Requests that change state need the control_header,
+// and the [http] token as a bearer token when one is set.
+//
+//	POST /api/run              start a run now
+//	POST /api/cancel           cancel the current run
+//	GET  /api/progress         progress of the current run
+//	GET  /api/runs?n=10        latest runs without their findings
+//	GET  /api/runs/{id}        one run with its findings
+//	GET  /api/findings?runs=10 findings of the latest runs
+//	GET  /api/issues           every issue with its status
+//	GET  /api/reports          report files, newest first
+//	GET  /api/reports/{name}   one report file
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/run", post(run))
+	mux.HandleFunc("/api/cancel", post(cancel))
+	mux.HandleFunc("/api/progress", get(progress))
+	mux.HandleFunc("/api/runs", get(runs))
+	mux.HandleFunc("/api/runs/", get(runByID))
+	mux.HandleFunc("/api/findings", get(findings))
+	mux.HandleFunc("/api/issues", get(issues))
+	mux.HandleFunc("/api/reports", get(reports))
+	mux.HandleFunc("/api/reports/", get(reportFile))
+}
+
+// RunSummary is a run without its findings.
+type RunSummary struct {
+	ID             uint64 `json:"id"`
+	Start          int64  `json:"start"`
+	End            int64  `json:"end"`
+	ReleaseVersion string `json:"releaseVersion"`
+	TestVersion    string `json:"testVersion"`
+	FirstOrder     uint64 `json:"firstOrder"`
+	LastOrder      uint64 `json:"lastOrder"`
+	Findings       int    `json:"findings"`
+	Canceled       bool   `json:"canceled,omitempty"`
+}
+
+// RunFinding is a finding with the run it was found in.
+type RunFinding struct {
+	Run uint64 `json:"run"`
+	history.Finding
+}
+
+type ReportFile struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"`
+}
+
+func run(w http.ResponseWriter, r *http.Request) {
+	if err := test.Start(); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, test.CurrentProgress())
+}
+
+func cancel(w http.ResponseWriter, r *http.Request) {
+	if err := test.Cancel(); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, test.CurrentProgress())
+}
+
+func progress(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, test.CurrentProgress())
+}
+
+func runs(w http.ResponseWriter, r *http.Request) {
+	latest, ok := latestRuns(w, r, "n")
+	if !ok {
+		return
+	}
+	summaries := make([]RunSummary, 0, len(latest))
+	for _, run := range latest {
+		summaries = append(summaries, RunSummary{
+			ID:             run.ID,
+			Start:          run.Start,
+			End:            run.End,
+			ReleaseVersion: run.ReleaseVersion,
+			TestVersion:    run.TestVersion,
+			FirstOrder:     run.FirstOrder,
+			LastOrder:      run.LastOrder,
+			Findings:       len(run.Findings),
+			Canceled:       run.Canceled,
+		})
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func runByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/api/runs/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("bad run id, %s", err.Error()))
+		return
+	}
+	h, err := test.OpenHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	run, err := h.Get(id)
+	h.Close()
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %d not found", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func findings(w http.ResponseWriter, r *http.Request) {
+	latest, ok := latestRuns(w, r, "runs")
+	if !ok {
+		return
+	}
+	rs := make([]RunFinding, 0)
+	for _, run := range latest {
+		for _, f := range run.Findings {
+			rs = append(rs, RunFinding{Run: run.ID, Finding: f})
+		}
+	}
+	writeJSON(w, http.StatusOK, rs)
+}
+
+func issues(w http.ResponseWriter, r *http.Request) {
+	h, err := test.OpenHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	list, err := h.Issues()
+	h.Close()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// latestRuns returns the number of latest runs asked for in the query
+// parameter, newest first. It writes the error itself.
+func latestRuns(w http.ResponseWriter, r *http.Request, param string) ([]*history.Run, bool) {
+	n := default_runs
+	if value := r.URL.Query().Get(param); value != "" {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%s must be a positive number", param))
+			return nil, false
+		}
+	}
+	h, err := test.OpenHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	all, err := h.Runs()
+	h.Close()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	latest := make([]*history.Run, 0, n)
+	for i := len(all) - 1; i >= 0 && len(latest) < n; i-- {
+		latest = append(latest, all[i])
+	}
+	return latest, true
+}
+
+func reports(w http.ResponseWriter, r *http.Request) {
+	infos, err := ioutil.ReadDir(conf.Setting.Report.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	files := make([]ReportFile, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || strings.HasSuffix(info.Name(), ".tmp") {
+			continue
+		}
+		files = append(files, ReportFile{Name: info.Name(), Size: info.Size(), Modified: info.ModTime().Unix()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Modified > files[j].Modified
+	})
+	writeJSON(w, http.StatusOK, files)
+}
+
+func reportFile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/reports/")
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("bad report name %q", name))
+		return
+	}
+	path := filepath.Join(conf.Setting.Report.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("report %s not found", name))
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+func get(fn http.HandlerFunc) http.HandlerFunc {
+	return method(http.MethodGet, fn)
+}
+
+func post(fn http.HandlerFunc) http.HandlerFunc {
+	return method(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(control_header) == "" {
+			writeError(w, http.StatusForbidden, fmt.Errorf("%s header required", control_header))
+			return
+		}
+		if token := conf.Setting.HTTP.Token; token != "" &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("bad token"))
+			return
+		}
+		fn(w, r)
+	})
+}
+
+func method(name string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != name {
+			w.Header().Set("Allow", name)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s only", name))
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
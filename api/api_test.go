@@ -0,0 +1,111 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bCoder778/qitmeer_test/conf"
+)
+
+func serve(method, path string, header map[string]string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	Register(mux)
+	r := httptest.NewRequest(method, path, nil)
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestControl(t *testing.T) {
+	defer func(token string) { conf.Setting.HTTP.Token = token }(conf.Setting.HTTP.Token)
+	control := map[string]string{control_header: "1"}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		token  string
+		status int
+	}{
+		{name: "get run", method: http.MethodGet, path: "/api/run", header: control, status: http.StatusMethodNotAllowed},
+		{name: "post progress", method: http.MethodPost, path: "/api/progress", status: http.StatusMethodNotAllowed},
+		// a form posted by a web page can not set the header
+		{name: "no header", method: http.MethodPost, path: "/api/run", status: http.StatusForbidden},
+		{name: "no header cancel", method: http.MethodPost, path: "/api/cancel", status: http.StatusForbidden},
+		{name: "no token", method: http.MethodPost, path: "/api/cancel", header: control, token: "secret", status: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodPost, path: "/api/cancel", token: "secret", status: http.StatusUnauthorized,
+			header: map[string]string{control_header: "1", "Authorization": "Bearer other"}},
+		{name: "cancel idle", method: http.MethodPost, path: "/api/cancel", header: control, status: http.StatusConflict},
+		{name: "cancel idle with token", method: http.MethodPost, path: "/api/cancel", token: "secret", status: http.StatusConflict,
+			header: map[string]string{control_header: "1", "Authorization": "Bearer secret"}},
+		{name: "progress", method: http.MethodGet, path: "/api/progress", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		conf.Setting.HTTP.Token = test.token
+		if w := serve(test.method, test.path, test.header); w.Code != test.status {
+			t.Errorf("%s: status %d, want %d, %s", test.name, w.Code, test.status, w.Body.String())
+		}
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	for _, path := range []string{"/api/runs?n=0", "/api/runs?n=x", "/api/findings?runs=-1", "/api/runs/abc", "/api/runs/"} {
+		if w := serve(http.MethodGet, path, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", path, w.Code)
+		}
+	}
+}
+
+func TestReportFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(reports string) { conf.Setting.Report.Dir = reports }(conf.Setting.Report.Dir)
+	conf.Setting.Report.Dir = filepath.Join(dir, "reports")
+	if err := os.Mkdir(conf.Setting.Report.Dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join("reports", "report-1.json"):     "{}",
+		filepath.Join("reports", "report-2.json.tmp"): "",
+		filepath.Join("reports", ".hidden"):           "",
+		"secret":                                      "secret",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w := serve(http.MethodGet, "/api/reports/report-1.json", nil); w.Code != http.StatusOK || w.Body.String() != "{}" {
+		t.Fatalf("report status %d, %q", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/api/reports", nil); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), "report-1.json") || strings.Contains(w.Body.String(), ".tmp") {
+		t.Fatalf("reports status %d, %s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/api/reports/report-3.json", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("missing report status %d", w.Code)
+	}
+
+	// The mux cleans the paths it routes, call the handler with the names
+	// it would see from a raw request
+	for _, name := range []string{"../secret", "..", ".hidden", "a/../../secret", ""} {
+		r := httptest.NewRequest(http.MethodGet, "/api/reports/x", nil)
+		r.URL.Path = "/api/reports/" + name
+		w := httptest.NewRecorder()
+		reportFile(w, r)
+		if w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "secret\n") {
+			t.Errorf("%q: status %d, %q", name, w.Code, w.Body.String())
+		}
+	}
+}
@@ -4,27 +4,52 @@ import (
 	"fmt"
 	"github.com/bCoder778/log"
 	"github.com/bCoder778/qitmeer_test/check/check_db"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/history"
+	"github.com/bCoder778/qitmeer_test/metrics"
+	"github.com/bCoder778/qitmeer_test/notify"
+	"github.com/bCoder778/qitmeer_test/progress"
 	"github.com/bCoder778/qitmeer_test/rpc"
-	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
 const release_db = "release_db"
 const test_db = "test_db"
+const series_points = 20
+
+// recheck_orders is how many of the orders applied by an earlier run are
+// fetched again, to find the blocks that changed since.
+const recheck_orders = 100
 
 type Check struct {
 	releaseVerify *FeesVerify
 	testVerify    *FeesVerify
+	releaseDiff   *DifficultyVerify
+	testDiff      *DifficultyVerify
+	releaseTime   *TimestampVerify
+	testTime      *TimestampVerify
+	releaseScript *ScriptVerify
+	testScript    *ScriptVerify
+	seed          *check_db.SnapshotInfo
+	releaseTrack  *progress.Tracker
+	testTrack     *progress.Tracker
 	stop          chan bool
-	errs          []error
+	stopOnce      sync.Once
+	mutex         sync.RWMutex
+	findings      []history.Finding
+	known         map[string]bool
 	releaseVer    string
 	testVer       string
 	curBlock      uint64
-	start         int64
-	ReleaseCount  uint64
-	TestCount     uint64
-	ReleaseUtxo   uint64
-	TestUtxo      uint64
+	// firstOrder is the first order the run verified, rather than replayed
+	firstOrder   uint64
+	start        int64
+	ReleaseCount uint64
+	TestCount    uint64
+	ReleaseUtxo  uint64
+	TestUtxo     uint64
 }
 
 func New(releaseVer, testVer string) (*Check, error) {
@@ -36,64 +61,320 @@ func New(releaseVer, testVer string) (*Check, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create test verify failed!err=%s", err.Error())
 	}
-	return &Check{
+	c := &Check{
 		releaseVerify: releaseVerify,
 		testVerify:    testVerify,
+		releaseDiff:   NewDifficultyVerify(&conf.Setting.Difficulty, releaseVerify.db),
+		testDiff:      NewDifficultyVerify(&conf.Setting.Difficulty, testVerify.db),
+		releaseTime:   NewTimestampVerify(&conf.Setting.BlockTime),
+		testTime:      NewTimestampVerify(&conf.Setting.BlockTime),
+		releaseScript: NewScriptVerify(),
+		testScript:    NewScriptVerify(),
+		seed:          releaseVerify.Seed,
 		stop:          make(chan bool),
 		releaseVer:    releaseVer,
 		testVer:       testVer,
-		errs:          make([]error, 0),
+		findings:      make([]history.Finding, 0),
 		start:         time.Now().Unix(),
-	}, nil
+	}
+	c.firstOrder = c.StartOrder()
+	if start := c.firstOrder; start != 0 {
+		for _, d := range []*DifficultyVerify{c.releaseDiff, c.testDiff} {
+			if err := d.resume(start - 1); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+	}
+	if reason := c.retargetSkipped(); reason != "" {
+		log.Warnf("Difficulty retarget is not checked, %s", reason)
+	}
+	return c, nil
+}
+
+// retargetSkipped is why the difficulty retarget is not checked on the
+// nodes, empty when it is checked on both.
+func (c *Check) retargetSkipped() string {
+	reasons := make([]string, 0)
+	if reason := c.releaseDiff.Skipped; reason != "" {
+		reasons = append(reasons, "release "+reason)
+	}
+	if reason := c.testDiff.Skipped; reason != "" {
+		reasons = append(reasons, "test "+reason)
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// StartOrder is the first order to verify: genesis, the order after the
+// snapshot the databases were seeded with, or for databases kept from an
+// earlier run the last recheck_orders orders they applied.
+func (c *Check) StartOrder() uint64 {
+	var start uint64
+	if c.seed != nil {
+		start = c.seed.Order + 1
+	}
+	releaseLast, releaseOk := c.releaseVerify.db.LastOrder()
+	testLast, testOk := c.testVerify.db.LastOrder()
+	if !releaseOk || !testOk {
+		return start
+	}
+	last := releaseLast
+	if testLast < last {
+		last = testLast
+	}
+	if last+1 > start+recheck_orders {
+		start = last + 1 - recheck_orders
+	}
+	// The blocks up to the pruned order can not be rolled back
+	for _, f := range []*FeesVerify{c.releaseVerify, c.testVerify} {
+		if pruned, ok := f.db.PrunedOrder(); ok && pruned+1 > start {
+			start = pruned + 1
+		}
+	}
+	return start
 }
 
 func (c *Check) CheckNode(releaseBlocks chan *rpc.Block, testBlocks chan *rpc.Block) {
 
 	defer func() {
+		// A run canceled before its first block has nothing to account for
+		if c.Stopped() && c.ReleaseCount == 0 {
+			return
+		}
 		if err := c.VerifyAccount(); err != nil {
-			log.Mail(fmt.Sprintf("Verify account failed, %s", err.Error()))
-			c.errs = append(c.errs, err)
+			// Account findings are about the whole run, they are kept at order 0
+			// so the id is the same in every run
+			f := newFinding("account", 0, "", err)
+			metrics.Findings.Inc("account")
+			if !c.known[f.ID] {
+				notify.Send(&notify.Message{Severity: notify.Error, Subject: "Verify account failed",
+					Body: err.Error(), Group: f.Validator + "/" + f.Kind})
+			}
+			c.mutex.Lock()
+			c.findings = append(c.findings, f)
+			c.mutex.Unlock()
 		}
 	}()
 	for {
+		var reBlock, tsBlock *rpc.Block
+		var ok bool
 		select {
-		case _, _ = <-c.stop:
+		case <-c.stop:
 			return
-		default:
-			reBlock, ok := <-releaseBlocks
+		case reBlock, ok = <-releaseBlocks:
 			if !ok {
 				return
 			}
-			tsBlock, ok := <-testBlocks
+		}
+		select {
+		case <-c.stop:
+			return
+		case tsBlock, ok = <-testBlocks:
 			if !ok {
 				return
 			}
-			if err := c.VerifyConsistency(reBlock, tsBlock); err != nil {
-				log.Mail(fmt.Sprintf("Order %d verification of consistency failed", reBlock.Order), err.Error())
-				c.errs = append(c.errs, err)
+		}
+		replayed, err := c.replay(reBlock, tsBlock)
+		if err != nil {
+			c.found("rollback", reBlock, err)
+			c.Stop()
+			return
+		}
+		if !replayed {
+			if c.ReleaseCount == 0 {
+				c.mutex.Lock()
+				c.firstOrder = reBlock.Order
+				c.mutex.Unlock()
 			}
+			c.found("consistency", reBlock, c.VerifyConsistency(reBlock, tsBlock))
 			c.ReleaseCount++
-			if err := c.VerifyFees(reBlock, tsBlock); err != nil {
-				log.Mail(fmt.Sprintf("Order %d verification of fees failed", reBlock.Order), err.Error())
-				c.errs = append(c.errs, err)
-			}
+			c.found("fees", reBlock, c.VerifyFees(reBlock, tsBlock))
+			c.found("scripts", reBlock, c.VerifyScripts(reBlock, tsBlock))
+			c.found("coinbase", reBlock, c.VerifyCoinbase(reBlock, tsBlock))
 			c.TestCount++
-			c.curBlock = reBlock.Order
+			c.found("pow", reBlock, c.VerifyPow(reBlock, tsBlock))
+			c.found("difficulty", reBlock, c.VerifyDifficulty(reBlock, tsBlock))
+			c.found("timestamp", reBlock, c.VerifyTimestamp(reBlock, tsBlock))
 		}
+		c.mutex.Lock()
+		c.curBlock = reBlock.Order
+		c.mutex.Unlock()
+		c.updateMetrics(reBlock, tsBlock)
 	}
 }
 
-func (c *Check) SendReport() string {
+// replay skips the blocks the kept databases applied in an earlier run, it
+// only feeds them to the difficulty and timestamp windows. When a block at
+// one of those orders changed since, the databases and the windows are
+// rolled back to the order before it and the block is verified again.
+func (c *Check) replay(releaseBlock, testBlock *rpc.Block) (bool, error) {
+	order := releaseBlock.Order
+	releaseApplied, testApplied := c.releaseVerify.applied(order), c.testVerify.applied(order)
+	if !releaseApplied && !testApplied {
+		return false, nil
+	}
+	if releaseApplied && testApplied && c.releaseVerify.same(releaseBlock) && c.testVerify.same(testBlock) {
+		c.releaseDiff.verify(releaseBlock)
+		c.testDiff.verify(testBlock)
+		c.releaseTime.verify(releaseBlock)
+		c.testTime.verify(testBlock)
+		return true, nil
+	}
+	if order == 0 {
+		return false, withKind("genesis", fmt.Errorf("genesis hash=%s is not the one of the databases, remove %s and %s to start over.",
+			releaseBlock.Hash, release_db, test_db))
+	}
+	log.Warnf("Block order=%d changed since it was verified, rollback to order %d", order, order-1)
+	for _, f := range []*FeesVerify{c.releaseVerify, c.testVerify} {
+		if !f.applied(order) {
+			continue
+		}
+		if err := f.RollbackTo(order - 1); err != nil {
+			return false, fmt.Errorf("rollback to order %d failed, %s.", order-1, err.Error())
+		}
+	}
+	for _, d := range []*DifficultyVerify{c.releaseDiff, c.testDiff} {
+		if err := d.rewind(order - 1); err != nil {
+			return false, fmt.Errorf("rollback to order %d failed, %s.", order-1, err.Error())
+		}
+	}
+	c.releaseTime.rewind(order - 1)
+	c.testTime.rewind(order - 1)
+	return false, nil
+}
+
+func (c *Check) updateMetrics(releaseBlock, testBlock *rpc.Block) {
+	if c.releaseTrack != nil {
+		c.releaseTrack.Verified(releaseBlock.Order)
+	}
+	if c.testTrack != nil {
+		c.testTrack.Verified(testBlock.Order)
+	}
+	if supply := c.releaseVerify.Supply; len(supply) != 0 {
+		metrics.UtxoSupply.Set(float64(supply[len(supply)-1].Total), "release")
+	}
+	if supply := c.testVerify.Supply; len(supply) != 0 {
+		metrics.UtxoSupply.Set(float64(supply[len(supply)-1].Total), "test")
+	}
+}
+
+// found keeps a failed verification of the block for the report and mails
+// it unless it is already known from an earlier run.
+func (c *Check) found(validator string, block *rpc.Block, err error) {
+	if err == nil {
+		return
+	}
+	f := newFinding(validator, block.Order, block.Hash, err)
+	metrics.Findings.Inc(validator)
+	if !c.known[f.ID] {
+		notify.Send(&notify.Message{Severity: notify.Error, Subject: fmt.Sprintf("Order %d verification of %s failed", block.Order, validator),
+			Body: err.Error(), Group: f.Validator + "/" + f.Kind})
+	}
+	c.mutex.Lock()
+	c.findings = append(c.findings, f)
+	c.mutex.Unlock()
+}
+
+// SetTrackers sets the progress trackers of the nodes, the verified
+// blocks are recorded in them.
+func (c *Check) SetTrackers(release, test *progress.Tracker) {
+	c.releaseTrack = release
+	c.testTrack = test
+}
+
+// SetKnown sets the ids of the findings reported by earlier runs, they are
+// not mailed again when found.
+func (c *Check) SetKnown(known map[string]bool) {
+	c.known = known
+}
+
+// Run is the history record of the run so far. Its orders are the ones
+// verified, the orders replayed from an earlier run are not part of it.
+func (c *Check) Run() *history.Run {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return &history.Run{
+		Start:          c.start,
+		End:            time.Now().Unix(),
+		ReleaseVersion: c.releaseVer,
+		TestVersion:    c.testVer,
+		FirstOrder:     c.firstOrder,
+		LastOrder:      c.curBlock,
+		ReleaseCount:   c.ReleaseCount,
+		TestCount:      c.TestCount,
+		ReleaseUtxo:    c.ReleaseUtxo,
+		TestUtxo:       c.TestUtxo,
+		Findings:       c.findings,
+		Canceled:       c.Stopped(),
+	}
+}
+
+// SendReport lists the new and resolved findings in full when the changes
+// against the history are known, and every finding otherwise.
+func (c *Check) SendReport(changes *history.Changes) string {
 	rs := fmt.Sprintf("Test relase=%s, test=%s use=%ds, blockcount=%d, release-utxo=%d, test-utxo=%d, verify block %d and find %d errors.\n\n\n",
-		c.releaseVer, c.testVer, time.Now().Unix()-c.start, c.ReleaseCount, c.ReleaseUtxo, c.TestUtxo, c.curBlock, len(c.errs))
-	for _, err := range c.errs {
-		rs += err.Error() + "\n"
+		c.releaseVer, c.testVer, time.Now().Unix()-c.start, c.ReleaseCount, c.ReleaseUtxo, c.TestUtxo, c.curBlock, len(c.findings))
+	rs += fmt.Sprintf("release-scripts checked=%d skipped=%d, test-scripts checked=%d skipped=%d.\n\n",
+		c.releaseScript.Checked, c.releaseScript.Skipped, c.testScript.Checked, c.testScript.Skipped)
+	if c.seed != nil {
+		rs += fmt.Sprintf("Seeded with utxo snapshot order=%d, sha256=%s.\n\n", c.seed.Order, c.seed.Sum)
+	}
+	if reason := c.retargetSkipped(); reason != "" {
+		rs += fmt.Sprintf("Difficulty retarget not checked, %s.\n\n", reason)
 	}
+	if changes == nil {
+		for _, f := range c.findings {
+			rs += f.Message + "\n"
+		}
+	} else {
+		acked := 0
+		for _, issue := range changes.Recurring {
+			if issue.Acked {
+				acked++
+			}
+		}
+		rs += fmt.Sprintf("New findings %d:\n", len(changes.New))
+		for _, issue := range changes.New {
+			rs += fmt.Sprintf("[%s] %s\n", issue.ID, issue.Message)
+		}
+		rs += fmt.Sprintf("\nResolved findings %d:\n", len(changes.Resolved))
+		for _, issue := range changes.Resolved {
+			rs += fmt.Sprintf("[%s] first run %d, last run %d, %s\n", issue.ID, issue.FirstRun, issue.LastRun, issue.Message)
+		}
+		rs += fmt.Sprintf("\nRecurring findings %d, %d of them acknowledged.\n", len(changes.Recurring), acked)
+	}
+	rs += fmt.Sprintf("\n\nRelease %s difficulty:\n%s", c.releaseVer, c.releaseDiff.SeriesReport(series_points))
+	rs += fmt.Sprintf("\nTest %s difficulty:\n%s", c.testVer, c.testDiff.SeriesReport(series_points))
 	return rs
 }
 
+// Stop ends CheckNode before the next block, it does not wait and may be
+// called any number of times.
 func (c *Check) Stop() {
-	c.stop <- true
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// Done is closed once the check is stopped, the block syncs end with it.
+func (c *Check) Done() <-chan bool {
+	return c.stop
+}
+
+// Stopped tells if the check was stopped before the last block.
+func (c *Check) Stopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Progress is the last verified order and the number of findings so far,
+// it is safe to call while CheckNode runs.
+func (c *Check) Progress() (uint64, int) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.curBlock, len(c.findings)
 }
 
 func (c *Check) Close() {
@@ -103,95 +384,195 @@ func (c *Check) Close() {
 
 func (c *Check) VerifyConsistency(releaseBlock, testBlock *rpc.Block) error {
 	if releaseBlock.Order != testBlock.Order {
-		return fmt.Errorf("relase %s block %d, test %s block = %d.", c.releaseVer, releaseBlock.Order, c.testVer, testBlock.Order)
+		return withKind("order", fmt.Errorf("relase %s block %d, test %s block = %d.", c.releaseVer, releaseBlock.Order, c.testVer, testBlock.Order)).
+			diff("order", releaseBlock.Order, testBlock.Order)
 	}
 	if releaseBlock.Hash != testBlock.Hash {
-		return fmt.Errorf("relase %s block order=%d, hash=%s, test %s block order=%d, hash=%s.",
-			c.releaseVer, releaseBlock.Order, releaseBlock.Hash, c.testVer, testBlock.Order, testBlock.Hash)
+		return withKind("hash", fmt.Errorf("relase %s block order=%d, hash=%s, test %s block order=%d, hash=%s.",
+			c.releaseVer, releaseBlock.Order, releaseBlock.Hash, c.testVer, testBlock.Order, testBlock.Hash)).
+			diff("hash", releaseBlock.Hash, testBlock.Hash)
 	}
 	if releaseBlock.Txsvalid != testBlock.Txsvalid {
-		return fmt.Errorf("block order=%d, relase %s txsvalid=%v, test %s txsvalid=%v.",
-			releaseBlock.Order, c.releaseVer, releaseBlock.Txsvalid, c.testVer, testBlock.Txsvalid)
+		return withKind("txsvalid", fmt.Errorf("block order=%d, relase %s txsvalid=%v, test %s txsvalid=%v.",
+			releaseBlock.Order, c.releaseVer, releaseBlock.Txsvalid, c.testVer, testBlock.Txsvalid)).
+			diff("txsvalid", releaseBlock.Txsvalid, testBlock.Txsvalid)
 	}
 	if releaseBlock.IsBlue != testBlock.IsBlue {
-		return fmt.Errorf("block order=%d, relase %s isBlue=%d, test %s isBlue=%d.",
-			releaseBlock.Order, c.releaseVer, releaseBlock.IsBlue, c.testVer, testBlock.IsBlue)
+		return withKind("isblue", fmt.Errorf("block order=%d, relase %s isBlue=%d, test %s isBlue=%d.",
+			releaseBlock.Order, c.releaseVer, releaseBlock.IsBlue, c.testVer, testBlock.IsBlue)).
+			diff("isblue", releaseBlock.IsBlue, testBlock.IsBlue)
 	}
 	return nil
 }
 
 func (c *Check) VerifyFees(releaseBlock, testBlock *rpc.Block) error {
 	if err := c.releaseVerify.verify(releaseBlock); err != nil {
-		return fmt.Errorf("release %s %s", c.releaseVer, err.Error())
+		return nodeError("release", c.releaseVer, err)
 	}
 	if err := c.testVerify.verify(testBlock); err != nil {
-		return fmt.Errorf("test %s %s", c.testVer, err.Error())
+		return nodeError("test", c.testVer, err)
 	}
 	return nil
 }
 
+func (c *Check) VerifyDifficulty(releaseBlock, testBlock *rpc.Block) error {
+	return c.nodeErrors(c.releaseDiff.verify(releaseBlock), c.testDiff.verify(testBlock))
+}
+
+func (c *Check) VerifyTimestamp(releaseBlock, testBlock *rpc.Block) error {
+	return c.nodeErrors(c.releaseTime.verify(releaseBlock), c.testTime.verify(testBlock))
+}
+
+// VerifyScripts runs the scripts of the blocks against the outputs
+// VerifyFees found them to spend, so it must follow VerifyFees.
+func (c *Check) VerifyScripts(releaseBlock, testBlock *rpc.Block) error {
+	return c.nodeErrors(c.releaseScript.verify(releaseBlock, c.releaseVerify.spent),
+		c.testScript.verify(testBlock, c.testVerify.spent))
+}
+
+func (c *Check) VerifyCoinbase(releaseBlock, testBlock *rpc.Block) error {
+	return c.nodeErrors(verifyCoinbase(releaseBlock), verifyCoinbase(testBlock))
+}
+
 func (c *Check) Verify(releaseBlock, testBlock *rpc.Block) error {
-	if err := c.releaseVerify.verify(releaseBlock); err != nil {
-		return fmt.Errorf("release %s %s", c.releaseVer, err.Error())
-	}
-	if err := c.testVerify.verify(testBlock); err != nil {
-		return fmt.Errorf("test %s %s", c.testVer, err.Error())
-	}
-	return nil
+	return c.nodeErrors(c.releaseVerify.verify(releaseBlock), c.testVerify.verify(testBlock))
 }
 
 func (c *Check) VerifyAccount() error {
 	var err error
+	if conf.Setting.FullScan {
+		if err := c.testVerify.VerifyStats(); err != nil {
+			return nodeError("test", c.testVer, err)
+		}
+		if err := c.releaseVerify.VerifyStats(); err != nil {
+			return nodeError("release", c.releaseVer, err)
+		}
+	}
 	c.TestUtxo, err = c.testVerify.SumUTXO()
 	if err != nil {
-		return fmt.Errorf("test %s sum utxo failed, %s", c.testVer, err.Error())
+		return withKind("test-sum", fmt.Errorf("test %s sum utxo failed, %s", c.testVer, err.Error()))
 	}
 	c.ReleaseUtxo, err = c.releaseVerify.SumUTXO()
 	if err != nil {
-		return fmt.Errorf("relesase %s sum utxo failed, %s", c.releaseVer, err.Error())
+		return withKind("release-sum", fmt.Errorf("relesase %s sum utxo failed, %s", c.releaseVer, err.Error()))
 	}
-	correct := (c.TestCount-1)*12000000000 + 6524293004366634
-	if c.TestUtxo != correct {
-		return fmt.Errorf("test %s sum utxo=%d,blockcount=%d,correct=correct", c.testVer, c.TestUtxo, c.TestCount)
+	// The databases may hold the blocks of earlier runs, the supply follows
+	// from their last order rather than from the blocks of this run
+	if correct := c.supply(c.testVerify); c.TestUtxo != correct {
+		return withKind("test-supply", fmt.Errorf("test %s sum utxo=%d,blockcount=%d,correct=correct", c.testVer, c.TestUtxo, c.TestCount)).
+			diff("utxo", c.ReleaseUtxo, c.TestUtxo).diff("correct", correct, correct)
 	}
-	if c.ReleaseUtxo != correct {
-		return fmt.Errorf("release %s sum utxo=%d,blockcount=%d,correct=correct", c.releaseVer, c.ReleaseUtxo, c.ReleaseCount)
+	if correct := c.supply(c.releaseVerify); c.ReleaseUtxo != correct {
+		return withKind("release-supply", fmt.Errorf("release %s sum utxo=%d,blockcount=%d,correct=correct", c.releaseVer, c.ReleaseUtxo, c.ReleaseCount)).
+			diff("utxo", c.ReleaseUtxo, c.TestUtxo).diff("correct", correct, correct)
 	}
 	return nil
 }
 
+// supply is the amount the unspent outputs of the database should total.
+func (c *Check) supply(f *FeesVerify) uint64 {
+	last := f.db.LastBlockOrder()
+	if c.seed != nil {
+		return c.seed.Stats.Total + (last-c.seed.Order)*12000000000
+	}
+	return last*12000000000 + 6524293004366634
+}
+
+type SupplyPoint struct {
+	Order uint64
+	Total uint64
+	Count uint64
+}
+
 type FeesVerify struct {
-	db *check_db.CheckDB
+	db     *check_db.CheckDB
+	Seed   *check_db.SnapshotInfo
+	Supply []SupplyPoint
+	// spent are the outputs the last verified block spends, keyed by
+	// spentKey, nil when the block was not applied
+	spent map[string]*check_db.UTXO
 }
 
+// NewFeesVerify opens the database at path, it is kept across runs so a run
+// goes on from the last order of the one before. A new database is seeded
+// with the configured snapshot.
 func NewFeesVerify(path string) (*FeesVerify, error) {
-	if err := os.RemoveAll(path); err != nil {
-		return nil, fmt.Errorf("remove releaseDB failed!err=%s", err.Error())
+	db, err := check_db.NewCheckDB(conf.Setting.Backend, path)
+	if err != nil {
+		return nil, err
 	}
-	db, err := check_db.NewCheckDB(path)
+	prune, err := PruneSetting()
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
-	return &FeesVerify{db: db}, nil
+	if err := db.SetPrune(path, prune); err != nil {
+		db.Close()
+		return nil, err
+	}
+	f := &FeesVerify{db: db, Supply: make([]SupplyPoint, 0)}
+	f.Seed, _ = db.Seed()
+	if last, ok := db.LastOrder(); ok {
+		log.Infof("Resume %s after order %d", path, last)
+	} else if conf.Setting.Snapshot == "" {
+		if err := db.SetHeadersFrom(0); err != nil {
+			db.Close()
+			return nil, err
+		}
+	} else {
+		if f.Seed, err = db.ImportSnapshot(conf.Setting.Snapshot); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("import snapshot %s failed!err=%s", conf.Setting.Snapshot, err.Error())
+		}
+		log.Infof("Seed %s with utxo snapshot order=%d, utxo=%d, total=%d", path, f.Seed.Order, f.Seed.Stats.Count, f.Seed.Stats.Total)
+	}
+	return f, nil
 }
 
+// PruneSetting is the configured pruning. A resumed run rechecks the last
+// recheck_orders orders and may roll them back, so they must not be pruned.
+func PruneSetting() (*check_db.PruneOption, error) {
+	prune := &check_db.PruneOption{Mode: check_db.PruneMode(conf.Setting.Prune), Depth: conf.Setting.Depth}
+	if prune.Mode != check_db.PruneKeep && prune.Mode != "" && prune.Depth < recheck_orders {
+		return nil, fmt.Errorf("prune depth must be at least %d, got %d", recheck_orders, prune.Depth)
+	}
+	return prune, nil
+}
+
+// verify applies the utxo changes of the block and the last order marker in
+// one batch, so an interrupted run never leaves a half applied block. A
+// wrong fee is a finding about a block that was applied in full, any other
+// failure leaves the block out and the batch is thrown away.
 func (f *FeesVerify) verify(block *rpc.Block) error {
-	if _, err := f.checkBlockFee(block); err != nil {
+	f.spent = nil
+	spent := make(map[string]*check_db.UTXO)
+	batch := f.db.NewBlockBatch(block.Order, block.Hash)
+	_, err := f.checkBlockFee(batch, block, spent)
+	if e := asFinding(err); err != nil && (e == nil || e.kind != "fee") {
 		return err
 	}
-	f.db.UpdateLastOrder(block.Order)
-	return nil
+	batch.UpdateLastOrder(block.Order)
+	if cerr := batch.Commit(); cerr != nil {
+		return fmt.Errorf("commit block order=%d failed! %s.", block.Order, cerr.Error())
+	}
+	f.spent = spent
+	stats := batch.Stats()
+	f.Supply = append(f.Supply, SupplyPoint{Order: block.Order, Total: stats.Total, Count: stats.Count})
+	return err
 }
 
-func (f *FeesVerify) checkBlockFee(b *rpc.Block) (bool, error) {
+// checkBlockFee adds the utxo changes of the block to the batch and checks
+// the coinbase against the fees, the outputs the inputs spend are put in
+// spent.
+func (f *FeesVerify) checkBlockFee(batch *check_db.BlockBatch, b *rpc.Block, spent map[string]*check_db.UTXO) (bool, error) {
 	if !b.Txsvalid {
 		return true, nil
 	}
-	err := f.saveVouts(b)
+	err := f.saveVouts(batch, b)
 	if err != nil {
 		return false, fmt.Errorf("save utxo failed! %s.", err.Error())
 	}
-	err = f.updateVouts(b)
+	err = f.updateVouts(batch, b)
 	if err != nil {
 		return false, fmt.Errorf("update utxo failed! %s.", err.Error())
 	}
@@ -201,12 +582,17 @@ func (f *FeesVerify) checkBlockFee(b *rpc.Block) (bool, error) {
 	}
 
 	var coinbase uint64
+	var coinbaseVouts []uint64
 	var fee uint64
 	for _, tx := range b.Transactions {
 		if isCoinBase(&tx) {
-			coinbase = tx.Vout[0].Amount
+			// The reward may be split into several outputs, e.g. miner and fees
+			for _, vout := range tx.Vout {
+				coinbaseVouts = append(coinbaseVouts, vout.Amount)
+			}
+			coinbase += sumVout(tx.Vout)
 		} else if !tx.Duplicate {
-			vinAmount, err := f.sumVin(tx.Vin)
+			vinAmount, err := f.sumVin(batch, tx.Vin, spent)
 			if err != nil {
 				return false, err
 			}
@@ -217,29 +603,35 @@ func (f *FeesVerify) checkBlockFee(b *rpc.Block) (bool, error) {
 	fee += 12000000000
 	if coinbase != fee {
 		w := &check_db.Wrong{Hash: b.Hash, Order: b.Order, Coinbase: coinbase, CalCoinbase: fee}
-		f.db.AddWrong(w)
-		return false, fmt.Errorf("find wrong fee block order=%d, hash=%s, coinbase=%d, correct=%d.", w.Order, w.Hash, w.Coinbase, w.CalCoinbase)
+		batch.AddWrong(w)
+		return false, withKind("fee", fmt.Errorf("find wrong fee block order=%d, hash=%s, coinbase=%d, vouts=%v, correct=%d.", w.Order, w.Hash, w.Coinbase, coinbaseVouts, w.CalCoinbase)).
+			value("coinbase", w.Coinbase).value("correct", w.CalCoinbase)
 	}
 	return true, nil
 }
 
-func (f *FeesVerify) sumVin(vins []rpc.Vin) (uint64, error) {
+func (f *FeesVerify) sumVin(batch *check_db.BlockBatch, vins []rpc.Vin, spent map[string]*check_db.UTXO) (uint64, error) {
 	var sum uint64
 	for _, vin := range vins {
-		amount, err := f.db.GetUTXO(vin.Txid, vin.Vout)
+		utxo, err := batch.GetUTXO(vin.Txid, vin.Vout)
 		if err != nil {
 			return 0, fmt.Errorf("%s:%d %s.", vin.Txid, vin.Vout, err.Error())
 		}
-		sum += amount.Amount
+		spent[spentKey(vin.Txid, vin.Vout)] = utxo
+		sum += utxo.Amount
 	}
 	return sum, nil
 }
 
-func (f *FeesVerify) saveVouts(b *rpc.Block) error {
+func spentKey(txid string, vout uint64) string {
+	return fmt.Sprintf("%s:%d", txid, vout)
+}
+
+func (f *FeesVerify) saveVouts(batch *check_db.BlockBatch, b *rpc.Block) error {
 	for _, tx := range b.Transactions {
 		if !tx.Duplicate {
 			for index, vout := range tx.Vout {
-				if err := f.db.SaveUTXO(tx.Txid, uint64(index), &check_db.UTXO{Amount: vout.Amount}); err != nil {
+				if err := batch.SaveUTXO(tx.Txid, uint64(index), &check_db.UTXO{Amount: vout.Amount, Script: vout.ScriptPubKey.Hex}); err != nil {
 					return err
 				}
 			}
@@ -248,12 +640,12 @@ func (f *FeesVerify) saveVouts(b *rpc.Block) error {
 	return nil
 }
 
-func (f *FeesVerify) updateVouts(b *rpc.Block) error {
+func (f *FeesVerify) updateVouts(batch *check_db.BlockBatch, b *rpc.Block) error {
 	for _, tx := range b.Transactions {
 		if !tx.Duplicate {
 			for _, vin := range tx.Vin {
 				if vin.Txid != "" {
-					if err := f.db.UpdateUTXO(vin.Txid, vin.Vout, tx.Txid); err != nil {
+					if err := batch.UpdateUTXO(vin.Txid, vin.Vout, tx.Txid); err != nil {
 						return err
 					}
 				}
@@ -263,6 +655,29 @@ func (f *FeesVerify) updateVouts(b *rpc.Block) error {
 	return nil
 }
 
+// applied tells if the database applied the order in an earlier run.
+func (f *FeesVerify) applied(order uint64) bool {
+	last, ok := f.db.LastOrder()
+	return ok && order <= last
+}
+
+// same tells if the block is the one the database applied at its order,
+// blocks applied before their hashes were kept are taken to be.
+func (f *FeesVerify) same(b *rpc.Block) bool {
+	hash, ok := f.db.BlockHash(b.Order)
+	return !ok || hash == b.Hash
+}
+
+// RollbackTo rewinds the utxo set so verification can replay from order.
+func (f *FeesVerify) RollbackTo(order uint64) error {
+	return f.db.RollbackTo(order)
+}
+
+// VerifyStats checks the maintained utxo total against a full scan.
+func (f *FeesVerify) VerifyStats() error {
+	return f.db.VerifyStats()
+}
+
 func (f *FeesVerify) SumUTXO() (uint64, error) {
 	return f.db.SumUTXO()
 }
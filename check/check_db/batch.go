@@ -0,0 +1,124 @@
+package check_db
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/encode"
+)
+
+// BlockBatch collects the changes of one block, nothing is written until
+// Commit applies them together with the undo record of the block and the
+// last order marker.
+type BlockBatch struct {
+	db    *CheckDB
+	batch *base.Batch
+	utxos map[string]*UTXO
+	undo  *Undo
+	stats *Stats
+	err   error
+}
+
+func (c *CheckDB) NewBlockBatch(order uint64, hash string) *BlockBatch {
+	stats, err := c.Stats()
+	if err != nil {
+		stats = &Stats{}
+	}
+	return &BlockBatch{
+		stats: stats,
+		err:   err,
+		db:    c,
+		batch: c.base.NewBatch(),
+		utxos: make(map[string]*UTXO),
+		undo:  &Undo{Order: order, Hash: hash, Stats: *stats, Created: make([]string, 0), Spent: make([]UndoUTXO, 0), Wrongs: make([]string, 0)},
+	}
+}
+
+// GetUTXO sees the outputs saved earlier in the same batch.
+func (b *BlockBatch) GetUTXO(txId string, index uint64) (*UTXO, error) {
+	if utxo, ok := b.utxos[getOutKey(txId, index)]; ok {
+		cp := *utxo
+		return &cp, nil
+	}
+	return b.db.GetUTXO(txId, index)
+}
+
+func (b *BlockBatch) SaveUTXO(txId string, index uint64, utxo *UTXO) error {
+	bytes, err := utxo.Bytes()
+	if err != nil {
+		return err
+	}
+	key := getOutKey(txId, index)
+	prev, ok := b.utxos[key]
+	if !ok {
+		if prev, err = b.journal(txId, index); err != nil {
+			return err
+		}
+	}
+	b.stats.add(prev, -1)
+	b.stats.add(utxo, 1)
+	if b.db.prune != nil && prev != nil && prev.Spent == "" && utxo.Spent != "" {
+		b.batch.PutInBucket(spent_bucket, spentKey(b.undo.Order, key), []byte{})
+	}
+	cp := *utxo
+	b.utxos[key] = &cp
+	b.batch.PutInBucket(tx_bucket, []byte(key), bytes)
+	return nil
+}
+
+// journal remembers the state of an output before the block first touches
+// it and returns that state, nil for a new output.
+func (b *BlockBatch) journal(txId string, index uint64) (*UTXO, error) {
+	key := getOutKey(txId, index)
+	prev, err := b.db.GetUTXO(txId, index)
+	if err == base.ErrNotFound {
+		b.undo.Created = append(b.undo.Created, key)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.undo.Spent = append(b.undo.Spent, UndoUTXO{Key: key, UTXO: *prev})
+	return prev, nil
+}
+
+func (b *BlockBatch) UpdateUTXO(txId string, index uint64, spent string) error {
+	utxo, err := b.GetUTXO(txId, index)
+	if err != nil {
+		return err
+	}
+	utxo.Spent = spent
+	return b.SaveUTXO(txId, index, utxo)
+}
+
+func (b *BlockBatch) AddWrong(w *Wrong) {
+	bytes, _ := w.Bytes()
+	b.undo.Wrongs = append(b.undo.Wrongs, w.Hash)
+	b.batch.PutInBucket(result_bucket, []byte(w.Hash), bytes)
+}
+
+func (b *BlockBatch) UpdateLastOrder(order uint64) {
+	b.batch.PutInBucket(block_bucket, []byte(block_bucket), encode.Uint64ToBytes(order))
+}
+
+// Stats is the utxo total including the changes of the batch.
+func (b *BlockBatch) Stats() Stats {
+	return *b.stats
+}
+
+func (b *BlockBatch) Commit() error {
+	if b.err != nil {
+		return fmt.Errorf("load utxo stats failed, %s", b.err.Error())
+	}
+	b.batch.PutInBucket(block_bucket, []byte(stats_key), b.stats.Bytes())
+	bytes, err := b.undo.Bytes()
+	if err != nil {
+		return err
+	}
+	b.batch.PutInBucket(undo_bucket, encode.Uint64ToBytes(b.undo.Order), bytes)
+	if prune := b.db.prune; prune != nil && b.undo.Order >= prune.Depth {
+		if err := b.db.pruneTo(b.batch, b.undo.Order-prune.Depth); err != nil {
+			return fmt.Errorf("prune failed, %s", err.Error())
+		}
+	}
+	return b.db.base.Write(b.batch)
+}
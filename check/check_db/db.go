@@ -1,7 +1,6 @@
 package check_db
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/bCoder778/qitmeer_test/db/base"
 	"github.com/bCoder778/qitmeer_test/encode"
@@ -11,30 +10,50 @@ const (
 	block_bucket  = "block_bucket"
 	tx_bucket     = "tx_bucket"
 	result_bucket = "result_bucket"
+	undo_bucket   = "undo_bucket"
 )
 
 type CheckDB struct {
-	base *base.Base
+	base    *base.Base
+	backend string
+	archive *base.Base
+	prune   *PruneOption
 }
 
-func NewCheckDB(path string) (*CheckDB, error) {
-	base, err := base.Open(path)
+// NewCheckDB opens the database at path with the given store backend,
+// leveldb or memory.
+func NewCheckDB(backend, path string) (*CheckDB, error) {
+	base, err := base.OpenBackend(backend, path)
 	if err != nil {
 		return nil, err
 	}
-	return &CheckDB{base}, nil
+	c := &CheckDB{base: base, backend: backend}
+	if err := base.Upgrade(schema_json, c.migrations()); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("open %s failed, %s", path, err.Error())
+	}
+	return c, nil
 }
 
 func (c *CheckDB) Close() {
+	if c.archive != nil {
+		c.archive.Close()
+	}
 	c.base.Close()
 }
 
 func (c *CheckDB) LastBlockOrder() uint64 {
+	order, _ := c.LastOrder()
+	return order
+}
+
+// LastOrder is the last applied order, there is none in a new database.
+func (c *CheckDB) LastOrder() (uint64, bool) {
 	bytes, err := c.base.GetFromBucket(block_bucket, []byte(block_bucket))
 	if err != nil {
-		return 0
+		return 0, false
 	}
-	return encode.BytesToUint64(bytes)
+	return encode.BytesToUint64(bytes), true
 }
 
 func (c *CheckDB) UpdateLastOrder(order uint64) {
@@ -61,79 +80,7 @@ func (c *CheckDB) GetUTXO(txId string, index uint64) (*UTXO, error) {
 	if err != nil {
 		return nil, err
 	}
-	var utxo *UTXO
-	err = json.Unmarshal(bytes, &utxo)
-	if err != nil {
-		return nil, err
-	}
-	return utxo, nil
-}
-
-func (c *CheckDB) SaveUTXO(txId string, index uint64, uxto *UTXO) error {
-	bytes, err := json.Marshal(uxto)
-	if err != nil {
-		return err
-	}
-	return c.base.PutInBucket(tx_bucket, []byte(getOutKey(txId, index)), bytes)
-}
-
-func (c *CheckDB) UpdateUTXO(txId string, index uint64, spent string) error {
-	uxto, err := c.GetUTXO(txId, index)
-	if err != nil {
-		return err
-	}
-	uxto.Spent = spent
-	err = c.SaveUTXO(txId, index, uxto)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (c *CheckDB) SumUTXO() (uint64, error) {
-	var sum uint64
-	iter := c.base.Iter(tx_bucket)
-	defer iter.Release()
-
-	// Iter will affect RLP decoding and reallocate memory to value
-	for iter.Next() {
-		value := make([]byte, len(iter.Value()))
-		copy(value, iter.Value())
-		var utxo *UTXO
-		err := json.Unmarshal(value, &utxo)
-		if err != nil {
-			return 0, err
-		}
-		if utxo.Spent == "" {
-			sum += utxo.Amount
-		}
-	}
-	return sum, nil
-}
-
-type UTXO struct {
-	Amount uint64
-	Spent  string
-}
-
-type Wrong struct {
-	Order       uint64
-	Hash        string
-	Coinbase    uint64
-	CalCoinbase uint64
-}
-
-func (w *Wrong) Bytes() ([]byte, error) {
-	return json.Marshal(w)
-}
-
-func BytesToWrong(bytes []byte) (*Wrong, error) {
-	var w *Wrong
-	err := json.Unmarshal(bytes, &w)
-	if err != nil {
-		return nil, err
-	}
-	return w, nil
+	return BytesToUTXO(bytes)
 }
 
 func getOutKey(txId string, idx interface{}) string {
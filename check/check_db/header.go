@@ -0,0 +1,96 @@
+package check_db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/encode"
+)
+
+const (
+	header_bucket = "header_bucket"
+	// headers_key is the first order whose headers are kept, databases
+	// written before headers were kept have none
+	headers_key = "headers_from"
+)
+
+// Header is what the difficulty retarget needs of a block. Count is the
+// number of blocks of the pow type up to and including this one, so the
+// windows can be rebuilt without the headers before them. Headers are not
+// pruned, they are small and the windows reach far back.
+type Header struct {
+	PowType   int
+	Timestamp int64
+	Bits      uint32
+	Count     int64
+}
+
+func (h *Header) Bytes() ([]byte, error) {
+	return json.Marshal(h)
+}
+
+func BytesToHeader(bytes []byte) (*Header, error) {
+	var h *Header
+	err := json.Unmarshal(bytes, &h)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// SetHeadersFrom records that the headers of every block from order on are
+// kept.
+func (c *CheckDB) SetHeadersFrom(order uint64) error {
+	return c.base.PutInBucket(block_bucket, []byte(headers_key), encode.Uint64ToBytes(order))
+}
+
+// HeadersFrom is the first order whose header is kept.
+func (c *CheckDB) HeadersFrom() (uint64, bool) {
+	bytes, err := c.base.GetFromBucket(block_bucket, []byte(headers_key))
+	if err != nil {
+		return 0, false
+	}
+	return encode.BytesToUint64(bytes), true
+}
+
+func (c *CheckDB) SaveHeader(order uint64, h *Header) error {
+	bytes, err := h.Bytes()
+	if err != nil {
+		return err
+	}
+	return c.base.PutInBucket(header_bucket, encode.Uint64ToBytes(order), bytes)
+}
+
+// Headers calls fn with the headers up to order, oldest first.
+func (c *CheckDB) Headers(order uint64, fn func(order uint64, h *Header)) error {
+	iter := c.base.Iter(header_bucket)
+	defer iter.Release()
+
+	for iter.Next() {
+		blockOrder := encode.BytesToUint64(base.LeafKeyToKey(header_bucket, iter.Key()))
+		if blockOrder > order {
+			break
+		}
+		h, err := BytesToHeader(iter.Value())
+		if err != nil {
+			return fmt.Errorf("decode header of order %d failed, %s", blockOrder, err.Error())
+		}
+		fn(blockOrder, h)
+	}
+	return iter.Error()
+}
+
+// deleteHeadersAfter adds the deletion of the headers after order to the
+// batch.
+func (c *CheckDB) deleteHeadersAfter(batch *base.Batch, order uint64) error {
+	iter := c.base.Iter(header_bucket)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := base.LeafKeyToKey(header_bucket, iter.Key())
+		if encode.BytesToUint64(key) > order {
+			batch.DeleteFromBucket(header_bucket, key)
+		}
+	}
+	return iter.Error()
+}
@@ -0,0 +1,143 @@
+package check_db
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/encode"
+)
+
+type PruneMode string
+
+const (
+	// PruneKeep keeps every spent output, for forensic runs
+	PruneKeep PruneMode = "keep"
+	// PruneDelete deletes spent outputs once they are older than the depth
+	PruneDelete PruneMode = "delete"
+	// PruneArchive moves them to a separate archive database instead
+	PruneArchive PruneMode = "archive"
+
+	spent_bucket  = "spent_bucket"
+	archive_path  = "_archive"
+	pruned_key    = "pruned_order"
+	order_key_len = 8
+)
+
+// PruneOption removes spent outputs spent more than Depth orders ago, the
+// undo records of those blocks are dropped with them so RollbackTo can go
+// back at most Depth orders.
+type PruneOption struct {
+	Mode  PruneMode
+	Depth uint64
+}
+
+func (c *CheckDB) SetPrune(path string, opt *PruneOption) error {
+	switch opt.Mode {
+	case PruneKeep, "":
+		c.prune = nil
+		return nil
+	case PruneDelete, PruneArchive:
+		// Commit prunes up to order-Depth, a depth of 0 would prune what the
+		// block just spent before it is verified
+		if opt.Depth < 1 {
+			return fmt.Errorf("prune depth must be at least 1, got %d", opt.Depth)
+		}
+	default:
+		return fmt.Errorf("unknown prune mode %s", opt.Mode)
+	}
+	if opt.Mode == PruneArchive {
+		archive, err := base.OpenBackend(c.backend, ArchivePath(path))
+		if err != nil {
+			return fmt.Errorf("open archive failed, %s", err.Error())
+		}
+		if err := archive.Upgrade(schema_archive, nil); err != nil {
+			archive.Close()
+			return fmt.Errorf("open archive failed, %s", err.Error())
+		}
+		c.archive = archive
+	}
+	c.prune = opt
+	return nil
+}
+
+// ArchivePath is where the archived outputs of the database at path live.
+func ArchivePath(path string) string {
+	return path + archive_path
+}
+
+// PrunedOrder is the latest order whose spent outputs were pruned.
+func (c *CheckDB) PrunedOrder() (uint64, bool) {
+	bytes, err := c.base.GetFromBucket(block_bucket, []byte(pruned_key))
+	if err != nil {
+		return 0, false
+	}
+	return encode.BytesToUint64(bytes), true
+}
+
+func spentKey(order uint64, key string) []byte {
+	return append(encode.Uint64ToBytes(order), key...)
+}
+
+// pruneTo adds the deletion of the outputs spent up to order and of their
+// undo records to the batch.
+func (c *CheckDB) pruneTo(batch *base.Batch, order uint64) error {
+	iter := c.base.Iter(spent_bucket)
+	for iter.Next() {
+		leaf := base.LeafKeyToKey(spent_bucket, iter.Key())
+		if encode.BytesToUint64(leaf[:order_key_len]) > order {
+			break
+		}
+		key := leaf[order_key_len:]
+		if c.archive != nil {
+			bytes, err := c.base.GetFromBucket(tx_bucket, key)
+			if err == nil {
+				err = c.archive.PutInBucket(tx_bucket, key, bytes)
+			}
+			if err != nil && err != base.ErrNotFound {
+				iter.Release()
+				return fmt.Errorf("archive %s failed, %s", string(key), err.Error())
+			}
+		}
+		batch.DeleteFromBucket(tx_bucket, copyBytes(key))
+		batch.Delete(copyBytes(iter.Key()))
+	}
+	err := iter.Error()
+	iter.Release()
+	if err != nil {
+		return err
+	}
+
+	iter = c.base.Iter(undo_bucket)
+	for iter.Next() {
+		if encode.BytesToUint64(base.LeafKeyToKey(undo_bucket, iter.Key())) > order {
+			break
+		}
+		batch.Delete(copyBytes(iter.Key()))
+	}
+	err = iter.Error()
+	iter.Release()
+	if err != nil {
+		return err
+	}
+	batch.PutInBucket(block_bucket, []byte(pruned_key), encode.Uint64ToBytes(order))
+	return nil
+}
+
+// unspendAfter removes the spent index entries of the blocks after order.
+func (c *CheckDB) unspendAfter(batch *base.Batch, order uint64) error {
+	iter := c.base.Iter(spent_bucket)
+	defer iter.Release()
+
+	for iter.Next() {
+		leaf := base.LeafKeyToKey(spent_bucket, iter.Key())
+		if encode.BytesToUint64(leaf[:order_key_len]) > order {
+			batch.Delete(copyBytes(iter.Key()))
+		}
+	}
+	return iter.Error()
+}
+
+func copyBytes(b []byte) []byte {
+	cp := make([]byte, len(b))
+	copy(cp, b)
+	return cp
+}
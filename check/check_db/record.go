@@ -0,0 +1,153 @@
+package check_db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/encode"
+)
+
+// Records are stored as a version byte followed by the encode fields.
+// Databases written before the binary encoding hold JSON objects, which
+// start with '{' and are still decoded.
+const (
+	record_version byte = 1
+	json_record    byte = '{'
+
+	migrate_batch_size = 10000
+)
+
+type UTXO struct {
+	Amount uint64
+	Script string
+	Spent  string
+}
+
+func (u *UTXO) Bytes() ([]byte, error) {
+	w := encode.NewWriter(16 + len(u.Script)/2 + len(u.Spent)/2)
+	w.Byte(record_version)
+	w.Uint64(u.Amount)
+	w.Hex(u.Script)
+	w.Hex(u.Spent)
+	return w.Result(), nil
+}
+
+func BytesToUTXO(bytes []byte) (*UTXO, error) {
+	if isJSONRecord(bytes) {
+		var u *UTXO
+		if err := json.Unmarshal(bytes, &u); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+	r, err := recordReader(bytes)
+	if err != nil {
+		return nil, err
+	}
+	u := &UTXO{Amount: r.Uint64(), Script: r.Hex(), Spent: r.Hex()}
+	if err := r.Error(); err != nil {
+		return nil, fmt.Errorf("decode utxo failed, %s", err.Error())
+	}
+	return u, nil
+}
+
+type Wrong struct {
+	Order       uint64
+	Hash        string
+	Coinbase    uint64
+	CalCoinbase uint64
+}
+
+func (w *Wrong) Bytes() ([]byte, error) {
+	wr := encode.NewWriter(64)
+	wr.Byte(record_version)
+	wr.Uint64(w.Order)
+	wr.Hex(w.Hash)
+	wr.Uint64(w.Coinbase)
+	wr.Uint64(w.CalCoinbase)
+	return wr.Result(), nil
+}
+
+func BytesToWrong(bytes []byte) (*Wrong, error) {
+	if isJSONRecord(bytes) {
+		var w *Wrong
+		if err := json.Unmarshal(bytes, &w); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	r, err := recordReader(bytes)
+	if err != nil {
+		return nil, err
+	}
+	w := &Wrong{Order: r.Uint64(), Hash: r.Hex(), Coinbase: r.Uint64(), CalCoinbase: r.Uint64()}
+	if err := r.Error(); err != nil {
+		return nil, fmt.Errorf("decode wrong failed, %s", err.Error())
+	}
+	return w, nil
+}
+
+func isJSONRecord(bytes []byte) bool {
+	return len(bytes) > 0 && bytes[0] == json_record
+}
+
+func recordReader(bytes []byte) (*encode.Reader, error) {
+	if len(bytes) == 0 {
+		return nil, encode.ErrShortBuffer
+	}
+	if bytes[0] != record_version {
+		return nil, fmt.Errorf("unknown record version %d", bytes[0])
+	}
+	return encode.NewReader(bytes[1:]), nil
+}
+
+// MigrateEncoding rewrites the JSON records of a database created before the
+// binary encoding, it returns how many records were converted.
+func (c *CheckDB) MigrateEncoding() (int, error) {
+	count := 0
+	for _, bucket := range []string{tx_bucket, result_bucket} {
+		batch := c.base.NewBatch()
+		iter := c.base.Iter(bucket)
+		for iter.Next() {
+			if !isJSONRecord(iter.Value()) {
+				continue
+			}
+			var bytes []byte
+			var err error
+			if bucket == tx_bucket {
+				var u *UTXO
+				if u, err = BytesToUTXO(iter.Value()); err == nil {
+					bytes, err = u.Bytes()
+				}
+			} else {
+				var w *Wrong
+				if w, err = BytesToWrong(iter.Value()); err == nil {
+					bytes, err = w.Bytes()
+				}
+			}
+			if err != nil {
+				iter.Release()
+				return count, fmt.Errorf("migrate %s failed, %s", string(iter.Key()), err.Error())
+			}
+			key := make([]byte, len(iter.Key()))
+			copy(key, iter.Key())
+			batch.Put(key, bytes)
+			count++
+			if batch.Len() >= migrate_batch_size {
+				if err := c.base.Write(batch); err != nil {
+					iter.Release()
+					return count, err
+				}
+				batch.Reset()
+			}
+		}
+		err := iter.Error()
+		iter.Release()
+		if err != nil {
+			return count, err
+		}
+		if err := c.base.Write(batch); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,161 @@
+package check_db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"testing"
+)
+
+var benchUTXO = &UTXO{
+	Amount: 12000000000,
+	Script: "76a914c0f0b73c320e1fe38eb1166a57b953e509c8f93e88ac",
+	Spent:  "b6d3ba2b9f3e4c1a0c2f33c0fd82b7fcff3d7a5c5e7c2b4f5e2c3d4a5b6c7d8e",
+}
+
+func TestUTXORoundTrip(t *testing.T) {
+	for _, u := range []*UTXO{
+		benchUTXO,
+		{},
+		{Amount: 1, Script: "not hex"},
+		{Amount: 2, Script: "ABCD"},
+	} {
+		bytes, err := u.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := BytesToUTXO(bytes)
+		if err != nil {
+			t.Fatalf("%+v: %v", u, err)
+		}
+		if *got != *u {
+			t.Fatalf("got %+v, want %+v", got, u)
+		}
+	}
+}
+
+func TestWrongRoundTrip(t *testing.T) {
+	w := &Wrong{Order: 7, Hash: "00ff", Coinbase: 3, CalCoinbase: 4}
+	bytes, err := w.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := BytesToWrong(bytes)
+	if err != nil || *got != *w {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+}
+
+// Records written before the binary encoding are JSON objects.
+func TestDecodeJSONRecords(t *testing.T) {
+	bytes, _ := json.Marshal(benchUTXO)
+	u, err := BytesToUTXO(bytes)
+	if err != nil || *u != *benchUTXO {
+		t.Fatalf("utxo %+v, %v", u, err)
+	}
+	w := &Wrong{Order: 7, Hash: "h", Coinbase: 3, CalCoinbase: 4}
+	bytes, _ = json.Marshal(w)
+	got, err := BytesToWrong(bytes)
+	if err != nil || *got != *w {
+		t.Fatalf("wrong %+v, %v", got, err)
+	}
+}
+
+func TestDecodeBadRecords(t *testing.T) {
+	good, _ := benchUTXO.Bytes()
+	for name, bytes := range map[string][]byte{
+		"empty":     {},
+		"version":   append([]byte{9}, good[1:]...),
+		"truncated": good[:len(good)-3],
+	} {
+		if u, err := BytesToUTXO(bytes); err == nil {
+			t.Errorf("%s: decoded %+v", name, u)
+		}
+	}
+}
+
+func TestMigrateEncoding(t *testing.T) {
+	db := newMemoryDB(t)
+	defer db.Close()
+	bytes, _ := json.Marshal(benchUTXO)
+	db.base.PutInBucket(tx_bucket, []byte("a-0"), bytes)
+	bytes, _ = json.Marshal(&Wrong{Hash: "h"})
+	db.base.PutInBucket(result_bucket, []byte("h"), bytes)
+
+	count, err := db.MigrateEncoding()
+	if err != nil || count != 2 {
+		t.Fatalf("migrated %d, %v", count, err)
+	}
+	raw, _ := db.base.GetFromBucket(tx_bucket, []byte("a-0"))
+	if isJSONRecord(raw) {
+		t.Fatal("utxo still json")
+	}
+	if u, err := db.GetUTXO("a", 0); err != nil || *u != *benchUTXO {
+		t.Fatalf("utxo %+v, %v", u, err)
+	}
+	if count, _ := db.MigrateEncoding(); count != 0 {
+		t.Fatalf("migrated %d binary records again", count)
+	}
+}
+
+func BenchmarkUTXOEncode(b *testing.B) {
+	b.Run("json", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			json.Marshal(benchUTXO)
+		}
+	})
+	b.Run("binary", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchUTXO.Bytes()
+		}
+	})
+}
+
+func BenchmarkUTXODecode(b *testing.B) {
+	jsonBytes, _ := json.Marshal(benchUTXO)
+	binaryBytes, _ := benchUTXO.Bytes()
+	b.Run("json", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			BytesToUTXO(jsonBytes)
+		}
+	})
+	b.Run("binary", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			BytesToUTXO(binaryBytes)
+		}
+	})
+}
+
+// BenchmarkSumUTXO compares the maintained stats with the scan they
+// replaced.
+func BenchmarkSumUTXO(b *testing.B) {
+	db, err := NewCheckDB(base.BackendMemory, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	batch := db.NewBlockBatch(0, "b0")
+	for i := 0; i < 10000; i++ {
+		if err := batch.SaveUTXO(fmt.Sprintf("%064x", i), 0, benchUTXO); err != nil {
+			b.Fatal(err)
+		}
+	}
+	batch.UpdateLastOrder(0)
+	if err := batch.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("stats", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := db.SumUTXO(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := db.ScanUTXO(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
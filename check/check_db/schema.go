@@ -0,0 +1,33 @@
+package check_db
+
+import "github.com/bCoder778/qitmeer_test/db/base"
+
+// schema_json is the version of the databases written before schema
+// versioning, when records were JSON.
+const schema_json = 1
+
+// schema_archive is the version of the prune archives, they hold the
+// binary utxo records moved out of the database.
+const schema_archive = 1
+
+// migrations upgrade the databases written by older versions. Append new
+// versions at the end and never change a released one.
+func (c *CheckDB) migrations() []base.Migration {
+	return []base.Migration{
+		{
+			Version:     2,
+			Description: "binary utxo and wrong records",
+			Migrate: func(*base.Base) error {
+				_, err := c.MigrateEncoding()
+				return err
+			},
+		},
+		{
+			Version:     3,
+			Description: "maintained utxo stats",
+			Migrate: func(*base.Base) error {
+				return c.initStats()
+			},
+		},
+	}
+}
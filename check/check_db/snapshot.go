@@ -0,0 +1,348 @@
+package check_db
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/encode"
+	"hash"
+	"io"
+	"os"
+)
+
+// A snapshot file holds the unspent outputs at an order:
+//
+//	magic | version | order | records | 0 | count | total | sha256
+//
+// every record is a length prefixed key, amount and script, and the sha256
+// covers everything before it.
+const (
+	snapshot_magic   = "QTUTXO"
+	snapshot_version = 1
+
+	seed_key = "seed"
+	// import_key marks an import that has not finished, its outputs are
+	// removed before the import is tried again
+	import_key = "snapshot_import"
+)
+
+// SnapshotInfo describes the contents of a snapshot file.
+type SnapshotInfo struct {
+	Order uint64
+	Stats Stats
+	Sum   string
+}
+
+func (s *SnapshotInfo) Bytes() []byte {
+	w := encode.NewWriter(64)
+	w.Byte(record_version)
+	w.Uint64(s.Order)
+	w.Uint64(s.Stats.Total)
+	w.Uint64(s.Stats.Count)
+	w.Hex(s.Sum)
+	return w.Result()
+}
+
+func BytesToSnapshotInfo(bytes []byte) (*SnapshotInfo, error) {
+	r, err := recordReader(bytes)
+	if err != nil {
+		return nil, err
+	}
+	s := &SnapshotInfo{Order: r.Uint64(), Stats: Stats{Total: r.Uint64(), Count: r.Uint64()}, Sum: r.Hex()}
+	if err := r.Error(); err != nil {
+		return nil, fmt.Errorf("decode snapshot info failed, %s", err.Error())
+	}
+	return s, nil
+}
+
+// Seed is the snapshot the database was seeded with, if any.
+func (c *CheckDB) Seed() (*SnapshotInfo, bool) {
+	bytes, err := c.base.GetFromBucket(block_bucket, []byte(seed_key))
+	if err != nil {
+		return nil, false
+	}
+	info, err := BytesToSnapshotInfo(bytes)
+	if err != nil {
+		return nil, false
+	}
+	return info, true
+}
+
+// ExportSnapshot writes the unspent outputs as they were after order to
+// path. Blocks after order are reverted in memory with their undo records,
+// so order must not be pruned.
+func (c *CheckDB) ExportSnapshot(path string, order uint64) (*SnapshotInfo, error) {
+	last, ok := c.LastOrder()
+	if !ok {
+		return nil, fmt.Errorf("can not export order %d, no block is verified", order)
+	}
+	if order > last {
+		return nil, fmt.Errorf("can not export order %d, the last verified order is %d", order, last)
+	}
+	if pruned, ok := c.PrunedOrder(); ok && order < pruned {
+		return nil, fmt.Errorf("can not export order %d, blocks up to %d are pruned", order, pruned)
+	}
+	undos, err := c.undoAfter(order)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := c.Stats()
+	if err != nil {
+		return nil, err
+	}
+	// Latest first, so the state before the oldest block after order wins
+	reverted := make(map[string]*UTXO)
+	for _, u := range undos {
+		for i := range u.Spent {
+			reverted[u.Spent[i].Key] = &u.Spent[i].UTXO
+		}
+		for _, key := range u.Created {
+			reverted[key] = nil
+		}
+	}
+	if len(undos) != 0 {
+		expected = &undos[len(undos)-1].Stats
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.writeSnapshot(file, order, reverted)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && info.Stats != *expected {
+		err = fmt.Errorf("exported total=%d, count=%d, expected total=%d, count=%d",
+			info.Stats.Total, info.Stats.Count, expected.Total, expected.Count)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return info, os.Rename(tmp, path)
+}
+
+func (c *CheckDB) writeSnapshot(file io.Writer, order uint64, reverted map[string]*UTXO) (*SnapshotInfo, error) {
+	sum := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(file, sum))
+	info := &SnapshotInfo{Order: order}
+
+	w.WriteString(snapshot_magic)
+	w.WriteByte(snapshot_version)
+	w.Write(encode.Uint64ToBytes(order))
+
+	iter := c.base.Iter(tx_bucket)
+	defer iter.Release()
+	for iter.Next() {
+		key := string(base.LeafKeyToKey(tx_bucket, iter.Key()))
+		utxo, ok := reverted[key]
+		if !ok {
+			var err error
+			if utxo, err = BytesToUTXO(iter.Value()); err != nil {
+				return nil, fmt.Errorf("decode %s failed, %s", key, err.Error())
+			}
+		}
+		if utxo == nil || utxo.Spent != "" {
+			continue
+		}
+		record := encode.NewWriter(16 + len(key) + len(utxo.Script)/2)
+		record.String(key)
+		record.Uint64(utxo.Amount)
+		record.Hex(utxo.Script)
+		writeUvarint(w, uint64(len(record.Result())))
+		w.Write(record.Result())
+		info.Stats.add(utxo, 1)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	writeUvarint(w, 0)
+	w.Write(encode.Uint64ToBytes(info.Stats.Count))
+	w.Write(encode.Uint64ToBytes(info.Stats.Total))
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	info.Sum = fmt.Sprintf("%x", sum.Sum(nil))
+	if _, err := file.Write(sum.Sum(nil)); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func writeUvarint(w *bufio.Writer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	w.Write(tmp[:n])
+}
+
+// ReadSnapshot checks the snapshot at path and returns what it contains,
+// fn is called for every output when it is not nil. Nothing in the file
+// can be trusted until ReadSnapshot returned without error.
+func ReadSnapshot(path string, fn func(key string, utxo *UTXO) error) (*SnapshotInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := &hashReader{r: bufio.NewReader(file), sum: sha256.New()}
+	head := make([]byte, len(snapshot_magic)+1+8)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, fmt.Errorf("read snapshot header failed, %s", err.Error())
+	}
+	if string(head[:len(snapshot_magic)]) != snapshot_magic {
+		return nil, fmt.Errorf("%s is not a utxo snapshot", path)
+	}
+	if version := head[len(snapshot_magic)]; version != snapshot_version {
+		return nil, fmt.Errorf("unknown snapshot version %d", version)
+	}
+	info := &SnapshotInfo{Order: encode.BytesToUint64(head[len(snapshot_magic)+1:])}
+
+	for {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("read snapshot record failed, %s", err.Error())
+		}
+		if size == 0 {
+			break
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("read snapshot record failed, %s", err.Error())
+		}
+		record := encode.NewReader(buf)
+		key := record.String()
+		utxo := &UTXO{Amount: record.Uint64(), Script: record.Hex()}
+		if err := record.Error(); err != nil {
+			return nil, fmt.Errorf("decode snapshot record failed, %s", err.Error())
+		}
+		info.Stats.add(utxo, 1)
+		if fn != nil {
+			if err := fn(key, utxo); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	tail := make([]byte, 16)
+	if _, err := io.ReadFull(r, tail); err != nil {
+		return nil, fmt.Errorf("read snapshot trailer failed, %s", err.Error())
+	}
+	expected := r.sum.Sum(nil)
+	sum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r.r, sum); err != nil {
+		return nil, fmt.Errorf("read snapshot checksum failed, %s", err.Error())
+	}
+	if !bytes.Equal(sum, expected) {
+		return nil, fmt.Errorf("snapshot checksum mismatch, the file is corrupted")
+	}
+	if _, err := r.r.ReadByte(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the snapshot checksum")
+	}
+	count, total := encode.BytesToUint64(tail[:8]), encode.BytesToUint64(tail[8:])
+	if info.Stats.Count != count || info.Stats.Total != total {
+		return nil, fmt.Errorf("snapshot holds total=%d, count=%d, trailer says total=%d, count=%d",
+			info.Stats.Total, info.Stats.Count, total, count)
+	}
+	info.Sum = fmt.Sprintf("%x", expected)
+	return info, nil
+}
+
+// ImportSnapshot seeds an empty database with the outputs of the snapshot
+// at path, the order of the snapshot becomes the last verified order. The
+// outputs are written in chunks, an import that did not finish is marked
+// and its outputs are removed when it is tried again.
+func (c *CheckDB) ImportSnapshot(path string) (*SnapshotInfo, error) {
+	if _, err := c.base.GetFromBucket(block_bucket, []byte(import_key)); err == nil {
+		if err := c.clearOutputs(); err != nil {
+			return nil, fmt.Errorf("remove the outputs of an unfinished import failed, %s", err.Error())
+		}
+	} else {
+		iter := c.base.Iter(tx_bucket)
+		used := iter.Next()
+		iter.Release()
+		if used {
+			return nil, fmt.Errorf("can not import a snapshot into a database that already has outputs")
+		}
+	}
+	if _, err := ReadSnapshot(path, nil); err != nil {
+		return nil, err
+	}
+	if err := c.base.PutInBucket(block_bucket, []byte(import_key), []byte{}); err != nil {
+		return nil, err
+	}
+
+	batch := c.base.NewBatch()
+	info, err := ReadSnapshot(path, func(key string, utxo *UTXO) error {
+		bytes, err := utxo.Bytes()
+		if err != nil {
+			return err
+		}
+		batch.PutInBucket(tx_bucket, []byte(key), bytes)
+		if batch.Len() >= migrate_batch_size {
+			if err := c.base.Write(batch); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	batch.PutInBucket(block_bucket, []byte(stats_key), info.Stats.Bytes())
+	batch.PutInBucket(block_bucket, []byte(seed_key), info.Bytes())
+	batch.PutInBucket(block_bucket, []byte(block_bucket), encode.Uint64ToBytes(info.Order))
+	batch.DeleteFromBucket(block_bucket, []byte(import_key))
+	if err := c.base.Write(batch); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// clearOutputs removes every output in chunks.
+func (c *CheckDB) clearOutputs() error {
+	iter := c.base.Iter(tx_bucket)
+	defer iter.Release()
+
+	batch := c.base.NewBatch()
+	for iter.Next() {
+		batch.DeleteFromBucket(tx_bucket, base.LeafKeyToKey(tx_bucket, iter.Key()))
+		if batch.Len() >= migrate_batch_size {
+			if err := c.base.Write(batch); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return c.base.Write(batch)
+}
+
+// hashReader hashes the bytes as they are consumed, not as they are
+// buffered.
+type hashReader struct {
+	r   *bufio.Reader
+	sum hash.Hash
+}
+
+func (h *hashReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.sum.Write(p[:n])
+	return n, err
+}
+
+func (h *hashReader) ReadByte() (byte, error) {
+	b, err := h.r.ReadByte()
+	if err == nil {
+		h.sum.Write([]byte{b})
+	}
+	return b, err
+}
@@ -0,0 +1,107 @@
+package check_db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db := newMemoryDB(t)
+	defer db.Close()
+
+	if _, err := db.ExportSnapshot(filepath.Join(dir, "empty"), 0); err == nil {
+		t.Fatal("exported a database without blocks")
+	}
+	applyBlock(t, db, 0, "b0", 50)
+	applyBlock(t, db, 1, "b1", 30, "b0")
+	applyBlock(t, db, 2, "b2", 10)
+
+	tests := []struct {
+		order uint64
+		stats Stats
+	}{
+		{order: 0, stats: Stats{Total: 50, Count: 1}},
+		{order: 1, stats: Stats{Total: 30, Count: 1}},
+		{order: 2, stats: Stats{Total: 40, Count: 2}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, "snapshot")
+		info, err := db.ExportSnapshot(path, test.order)
+		if err != nil {
+			t.Fatalf("order %d: %v", test.order, err)
+		}
+		if info.Order != test.order || info.Stats != test.stats {
+			t.Fatalf("order %d: exported %+v", test.order, info)
+		}
+		read, err := ReadSnapshot(path, nil)
+		if err != nil || *read != *info {
+			t.Fatalf("order %d: read %+v, %v", test.order, read, err)
+		}
+
+		seeded := newMemoryDB(t)
+		imported, err := seeded.ImportSnapshot(path)
+		if err != nil || *imported != *info {
+			t.Fatalf("order %d: imported %+v, %v", test.order, imported, err)
+		}
+		if seed, ok := seeded.Seed(); !ok || *seed != *info {
+			t.Fatalf("order %d: seed %+v", test.order, seed)
+		}
+		if last, _ := seeded.LastOrder(); last != test.order {
+			t.Fatalf("order %d: last order %d", test.order, last)
+		}
+		if err := seeded.VerifyStats(); err != nil {
+			t.Fatal(err)
+		}
+		seeded.Close()
+	}
+	if _, err := db.ExportSnapshot(filepath.Join(dir, "late"), 3); err == nil {
+		t.Fatal("exported an order that is not verified")
+	}
+}
+
+// An import that stopped half way is marked, trying it again starts over
+// instead of refusing the database for the outputs it left.
+func TestImportSnapshotRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db := newMemoryDB(t)
+	defer db.Close()
+	applyBlock(t, db, 0, "b0", 50)
+	path := filepath.Join(dir, "snapshot")
+	info, err := db.ExportSnapshot(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seeded := newMemoryDB(t)
+	defer seeded.Close()
+	stray, _ := (&UTXO{Amount: 7}).Bytes()
+	seeded.base.PutInBucket(tx_bucket, []byte(getOutKey("stray", 0)), stray)
+	if _, err := seeded.ImportSnapshot(path); err == nil {
+		t.Fatal("imported into a database with outputs")
+	}
+	seeded.base.PutInBucket(block_bucket, []byte(import_key), []byte{})
+	imported, err := seeded.ImportSnapshot(path)
+	if err != nil || *imported != *info {
+		t.Fatalf("imported %+v, %v", imported, err)
+	}
+	if _, err := seeded.GetUTXO("stray", 0); err == nil {
+		t.Fatal("output of the unfinished import kept")
+	}
+	if _, err := seeded.base.GetFromBucket(block_bucket, []byte(import_key)); err == nil {
+		t.Fatal("import still marked unfinished")
+	}
+	if err := seeded.VerifyStats(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,118 @@
+package check_db
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/encode"
+)
+
+const stats_key = "utxo_stats"
+
+// Stats is the total amount and number of unspent outputs, it is updated in
+// the same batch as the outputs themselves.
+type Stats struct {
+	Total uint64
+	Count uint64
+}
+
+func (s *Stats) Bytes() []byte {
+	w := encode.NewWriter(20)
+	w.Byte(record_version)
+	w.Uint64(s.Total)
+	w.Uint64(s.Count)
+	return w.Result()
+}
+
+func BytesToStats(bytes []byte) (*Stats, error) {
+	r, err := recordReader(bytes)
+	if err != nil {
+		return nil, err
+	}
+	s := &Stats{Total: r.Uint64(), Count: r.Uint64()}
+	if err := r.Error(); err != nil {
+		return nil, fmt.Errorf("decode stats failed, %s", err.Error())
+	}
+	return s, nil
+}
+
+// add applies the contribution of an output, spent outputs count nothing.
+func (s *Stats) add(u *UTXO, sign int) {
+	if u == nil || u.Spent != "" {
+		return
+	}
+	if sign > 0 {
+		s.Total += u.Amount
+		s.Count++
+	} else {
+		s.Total -= u.Amount
+		s.Count--
+	}
+}
+
+// Stats returns the maintained stats, a database without them is empty as
+// the schema migration computes them for older ones.
+func (c *CheckDB) Stats() (*Stats, error) {
+	bytes, err := c.base.GetFromBucket(block_bucket, []byte(stats_key))
+	if err == base.ErrNotFound {
+		return &Stats{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return BytesToStats(bytes)
+}
+
+// SumUTXO returns the maintained unspent total without scanning.
+func (c *CheckDB) SumUTXO() (uint64, error) {
+	stats, err := c.Stats()
+	if err != nil {
+		return 0, err
+	}
+	return stats.Total, nil
+}
+
+// ScanUTXO computes the stats by iterating every output.
+func (c *CheckDB) ScanUTXO() (*Stats, error) {
+	stats := &Stats{}
+	iter := c.base.Iter(tx_bucket)
+	defer iter.Release()
+
+	for iter.Next() {
+		utxo, err := BytesToUTXO(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		stats.add(utxo, 1)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// VerifyStats compares the maintained stats with a full scan.
+func (c *CheckDB) VerifyStats() error {
+	stats, err := c.Stats()
+	if err != nil {
+		return err
+	}
+	scan, err := c.ScanUTXO()
+	if err != nil {
+		return err
+	}
+	if *stats != *scan {
+		return fmt.Errorf("maintained utxo total=%d, count=%d, scanned total=%d, count=%d",
+			stats.Total, stats.Count, scan.Total, scan.Count)
+	}
+	return nil
+}
+
+// initStats computes the stats of a database created before they were
+// maintained.
+func (c *CheckDB) initStats() error {
+	stats, err := c.ScanUTXO()
+	if err != nil {
+		return err
+	}
+	return c.base.PutInBucket(block_bucket, []byte(stats_key), stats.Bytes())
+}
@@ -0,0 +1,120 @@
+package check_db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/encode"
+)
+
+// Undo records what a block changed so it can be reverted: the outputs it
+// created, the previous state of the outputs it spent and the utxo stats
+// before the block. Hash tells if the block at the order is still the same,
+// records written before it was kept have none.
+type Undo struct {
+	Order   uint64
+	Hash    string `json:",omitempty"`
+	Stats   Stats
+	Created []string
+	Spent   []UndoUTXO
+	Wrongs  []string
+}
+
+type UndoUTXO struct {
+	Key  string
+	UTXO UTXO
+}
+
+func (u *Undo) Bytes() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+func BytesToUndo(bytes []byte) (*Undo, error) {
+	var u *Undo
+	err := json.Unmarshal(bytes, &u)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// BlockHash is the hash of the block applied at order, it is unknown for
+// pruned blocks and blocks applied before hashes were kept.
+func (c *CheckDB) BlockHash(order uint64) (string, bool) {
+	bytes, err := c.base.GetFromBucket(undo_bucket, encode.Uint64ToBytes(order))
+	if err != nil {
+		return "", false
+	}
+	u, err := BytesToUndo(bytes)
+	if err != nil || u.Hash == "" {
+		return "", false
+	}
+	return u.Hash, true
+}
+
+// undoAfter returns the undo records of the blocks after order, latest
+// first.
+func (c *CheckDB) undoAfter(order uint64) ([]*Undo, error) {
+	undos := make([]*Undo, 0)
+	iter := c.base.Iter(undo_bucket)
+	defer iter.Release()
+
+	for iter.Next() {
+		blockOrder := encode.BytesToUint64(base.LeafKeyToKey(undo_bucket, iter.Key()))
+		if blockOrder <= order {
+			continue
+		}
+		u, err := BytesToUndo(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("decode undo of order %d failed, %s", blockOrder, err.Error())
+		}
+		undos = append(undos, u)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(undos)-1; i < j; i, j = i+1, j-1 {
+		undos[i], undos[j] = undos[j], undos[i]
+	}
+	return undos, nil
+}
+
+// RollbackTo reverts every block applied after order, in one batch, and
+// makes order the last verified block. Pruned blocks can not be reverted.
+func (c *CheckDB) RollbackTo(order uint64) error {
+	if pruned, ok := c.PrunedOrder(); ok && order < pruned {
+		return fmt.Errorf("can not rollback to order %d, blocks up to %d are pruned", order, pruned)
+	}
+	undos, err := c.undoAfter(order)
+	if err != nil {
+		return err
+	}
+	batch := c.base.NewBatch()
+	if err := c.unspendAfter(batch, order); err != nil {
+		return err
+	}
+	if err := c.deleteHeadersAfter(batch, order); err != nil {
+		return err
+	}
+	if len(undos) != 0 {
+		batch.PutInBucket(block_bucket, []byte(stats_key), undos[len(undos)-1].Stats.Bytes())
+	}
+	for _, u := range undos {
+		for _, key := range u.Created {
+			batch.DeleteFromBucket(tx_bucket, []byte(key))
+		}
+		for _, spent := range u.Spent {
+			bytes, err := spent.UTXO.Bytes()
+			if err != nil {
+				return err
+			}
+			batch.PutInBucket(tx_bucket, []byte(spent.Key), bytes)
+		}
+		for _, hash := range u.Wrongs {
+			batch.DeleteFromBucket(result_bucket, []byte(hash))
+		}
+		batch.DeleteFromBucket(undo_bucket, encode.Uint64ToBytes(u.Order))
+	}
+	batch.PutInBucket(block_bucket, []byte(block_bucket), encode.Uint64ToBytes(order))
+	return c.base.Write(batch)
+}
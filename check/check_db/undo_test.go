@@ -0,0 +1,155 @@
+package check_db
+
+import (
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"testing"
+)
+
+func newMemoryDB(t *testing.T) *CheckDB {
+	db, err := NewCheckDB(base.BackendMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// applyBlock creates an output of amount and spends the outputs in spends.
+func applyBlock(t *testing.T, db *CheckDB, order uint64, hash string, amount uint64, spends ...string) {
+	batch := db.NewBlockBatch(order, hash)
+	if err := batch.SaveUTXO(hash, 0, &UTXO{Amount: amount}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range spends {
+		if err := batch.UpdateUTXO(tx, 0, hash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch.UpdateLastOrder(order)
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollbackTo(t *testing.T) {
+	db := newMemoryDB(t)
+	defer db.Close()
+
+	applyBlock(t, db, 0, "b0", 50)
+	applyBlock(t, db, 1, "b1", 30)
+	before, _ := db.Stats()
+
+	applyBlock(t, db, 2, "b2", 10, "b0")
+	batch := db.NewBlockBatch(3, "b3")
+	batch.AddWrong(&Wrong{Order: 3, Hash: "b3"})
+	if err := batch.UpdateUTXO("b1", 0, "b3"); err != nil {
+		t.Fatal(err)
+	}
+	batch.UpdateLastOrder(3)
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if hash, ok := db.BlockHash(3); !ok || hash != "b3" {
+		t.Fatalf("hash of order 3 %q %v", hash, ok)
+	}
+	if err := db.RollbackTo(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if last := db.LastBlockOrder(); last != 1 {
+		t.Fatalf("last order %d", last)
+	}
+	if stats, _ := db.Stats(); *stats != *before {
+		t.Fatalf("stats %+v, want %+v", stats, before)
+	}
+	for _, tx := range []string{"b0", "b1"} {
+		if utxo, err := db.GetUTXO(tx, 0); err != nil || utxo.Spent != "" {
+			t.Fatalf("%s not unspent, %+v %v", tx, utxo, err)
+		}
+	}
+	if _, err := db.GetUTXO("b2", 0); err != base.ErrNotFound {
+		t.Fatalf("output of a rolled back block kept, %v", err)
+	}
+	if len(db.WrongList()) != 0 {
+		t.Fatalf("wrong of a rolled back block kept")
+	}
+	if _, ok := db.BlockHash(2); ok {
+		t.Fatalf("hash of a rolled back block kept")
+	}
+	if hash, ok := db.BlockHash(1); !ok || hash != "b1" {
+		t.Fatalf("hash of order 1 %q %v", hash, ok)
+	}
+	if err := db.VerifyStats(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The orders can be applied again
+	applyBlock(t, db, 2, "b2x", 5, "b1")
+	if hash, _ := db.BlockHash(2); hash != "b2x" {
+		t.Fatalf("hash of the new order 2 %q", hash)
+	}
+}
+
+func TestRollbackPruned(t *testing.T) {
+	db := newMemoryDB(t)
+	defer db.Close()
+	if err := db.SetPrune("", &PruneOption{Mode: PruneDelete, Depth: 2}); err != nil {
+		t.Fatal(err)
+	}
+	applyBlock(t, db, 0, "b0", 50)
+	applyBlock(t, db, 1, "b1", 30, "b0")
+	applyBlock(t, db, 2, "b2", 10)
+	applyBlock(t, db, 3, "b3", 10)
+
+	if pruned, ok := db.PrunedOrder(); !ok || pruned != 1 {
+		t.Fatalf("pruned order %d %v", pruned, ok)
+	}
+	if _, err := db.GetUTXO("b0", 0); err != base.ErrNotFound {
+		t.Fatalf("spent output not pruned, %v", err)
+	}
+	if err := db.RollbackTo(0); err == nil {
+		t.Fatal("rolled back into pruned blocks")
+	}
+	if err := db.RollbackTo(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetUTXO("b2", 0); err != base.ErrNotFound {
+		t.Fatalf("output of a rolled back block kept, %v", err)
+	}
+}
+
+func TestRollbackHeaders(t *testing.T) {
+	db := newMemoryDB(t)
+	defer db.Close()
+
+	for order := uint64(0); order < 4; order++ {
+		applyBlock(t, db, order, "b", 50)
+		if err := db.SaveHeader(order, &Header{Timestamp: int64(order), Count: int64(order + 1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.RollbackTo(1); err != nil {
+		t.Fatal(err)
+	}
+	orders := make([]uint64, 0)
+	if err := db.Headers(10, func(order uint64, h *Header) { orders = append(orders, order) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[1] != 1 {
+		t.Fatalf("headers %v after the rollback", orders)
+	}
+}
+
+func TestSetPruneDepth(t *testing.T) {
+	db := newMemoryDB(t)
+	defer db.Close()
+
+	for _, opt := range []*PruneOption{{Mode: PruneDelete}, {Mode: PruneArchive}, {Mode: "forget", Depth: 10}} {
+		if err := db.SetPrune("", opt); err == nil {
+			t.Errorf("prune %s depth %d accepted", opt.Mode, opt.Depth)
+		}
+	}
+	if err := db.SetPrune("", &PruneOption{Mode: PruneKeep}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,82 @@
+package check
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"github.com/bCoder778/qitmeer_test/script"
+	"strings"
+)
+
+// verifyCoinbase checks that the block has exactly one coinbase, that it is
+// the first transaction, that it pays at least one output and that its script
+// starts with the block height.
+func verifyCoinbase(b *rpc.Block) error {
+	if b.Order == 0 {
+		return nil
+	}
+	errs := make([]string, 0)
+	count := 0
+	for i, tx := range b.Transactions {
+		if !isCoinBase(&tx) {
+			continue
+		}
+		count++
+		if i != 0 {
+			errs = append(errs, fmt.Sprintf("coinbase %s at index %d", tx.Txid, i))
+		}
+	}
+	if count != 1 {
+		errs = append(errs, fmt.Sprintf("%d coinbase transactions", count))
+	}
+	if len(b.Transactions) != 0 && isCoinBase(&b.Transactions[0]) {
+		coinbase := &b.Transactions[0]
+		if len(coinbase.Vout) == 0 {
+			errs = append(errs, "coinbase has no outputs")
+		}
+		height, err := coinbaseHeight(coinbase.Vin[0].Coinbase)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else if height != b.Height {
+			errs = append(errs, fmt.Sprintf("coinbase height=%d, block height=%d", height, b.Height))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("find wrong coinbase block order=%d, hash=%s, %s.", b.Order, b.Hash, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// coinbaseHeight decodes the script number pushed first by the coinbase
+// script.
+func coinbaseHeight(coinbase string) (uint64, error) {
+	raw, err := hex.DecodeString(coinbase)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coinbase script, %s", err.Error())
+	}
+	ops, err := script.Parse(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coinbase script, %s", err.Error())
+	}
+	if len(ops) == 0 {
+		return 0, fmt.Errorf("empty coinbase script")
+	}
+	if n, ok := ops[0].SmallInt(); ok {
+		return uint64(n), nil
+	}
+	data := ops[0].Data
+	if len(data) == 0 || len(data) > 8 {
+		return 0, fmt.Errorf("coinbase script does not start with the height")
+	}
+	if data[len(data)-1]&0x80 != 0 {
+		return 0, fmt.Errorf("coinbase height is negative")
+	}
+	if data[len(data)-1] == 0 && (len(data) == 1 || data[len(data)-2]&0x80 == 0) {
+		return 0, fmt.Errorf("coinbase height %x is not minimally encoded", data)
+	}
+	var height uint64
+	for i := len(data) - 1; i >= 0; i-- {
+		height = height<<8 | uint64(data[i])
+	}
+	return height, nil
+}
@@ -0,0 +1,69 @@
+package check
+
+import (
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"testing"
+)
+
+func TestCoinbaseHeight(t *testing.T) {
+	tests := []struct {
+		script string
+		height uint64
+		err    bool
+	}{
+		{script: "00", height: 0},
+		{script: "51", height: 1},
+		{script: "60", height: 16},
+		{script: "0111", height: 17},
+		{script: "017f", height: 127},
+		{script: "028000", height: 128},
+		{script: "03a08601", height: 100000},
+		{script: "03a08601062f503253482f", height: 100000},
+		{script: "08ffffffffffffff7f", height: 1<<63 - 1},
+		{script: "", err: true},
+		{script: "zz", err: true},
+		{script: "4c00", err: true},
+		{script: "4c", err: true},
+		{script: "0100", err: true},
+		{script: "021100", err: true},
+		{script: "0180", err: true},
+		{script: "09010203040506070809", err: true},
+		{script: "6a", err: true},
+	}
+	for _, test := range tests {
+		height, err := coinbaseHeight(test.script)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: got height %d, want an error", test.script, height)
+			}
+			continue
+		}
+		if err != nil || height != test.height {
+			t.Errorf("%q: got %d, %v, want %d", test.script, height, err, test.height)
+		}
+	}
+}
+
+func TestVerifyCoinbase(t *testing.T) {
+	coinbase := rpc.Transaction{Txid: "c", Vin: []rpc.Vin{{Coinbase: "03a08601"}}, Vout: []rpc.Vout{{}}}
+	spend := rpc.Transaction{Txid: "s", Vin: []rpc.Vin{{Txid: "c"}}}
+	tests := []struct {
+		name string
+		txs  []rpc.Transaction
+		err  bool
+	}{
+		{name: "good", txs: []rpc.Transaction{coinbase, spend}},
+		{name: "none", txs: []rpc.Transaction{spend}, err: true},
+		{name: "second", txs: []rpc.Transaction{coinbase, coinbase}, err: true},
+		{name: "not first", txs: []rpc.Transaction{spend, coinbase}, err: true},
+		{name: "no outputs", txs: []rpc.Transaction{{Txid: "c", Vin: coinbase.Vin}}, err: true},
+		{name: "wrong height", txs: []rpc.Transaction{{Txid: "c", Vin: []rpc.Vin{{Coinbase: "03a18601"}}, Vout: coinbase.Vout}}, err: true},
+		{name: "empty push", txs: []rpc.Transaction{{Txid: "c", Vin: []rpc.Vin{{Coinbase: "4c00"}}, Vout: coinbase.Vout}}, err: true},
+	}
+	for _, test := range tests {
+		err := verifyCoinbase(&rpc.Block{Order: 5, Height: 100000, Transactions: test.txs})
+		if (err != nil) != test.err {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package check
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/check/check_db"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/pow"
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"math/big"
+	"sort"
+)
+
+type diffNode struct {
+	order     uint64
+	timestamp int64
+	bits      uint32
+}
+
+type DiffPoint struct {
+	Order      uint64
+	Difficulty float64
+}
+
+// DifficultyVerify recomputes the expected bits of every block from the
+// preceding blocks mined with the same algorithm. The headers of the
+// windows are kept in the check database, so a run resuming a database
+// rebuilds them. When the database does not go back to genesis, as after a
+// snapshot, it does not know where the windows begin, it skips the
+// retarget and only checks the bits themselves.
+type DifficultyVerify struct {
+	params  map[pow.PowType]*conf.Retarget
+	db      *check_db.CheckDB
+	history map[pow.PowType][]*diffNode
+	count   map[pow.PowType]int64
+	// Skipped is why the retarget is not checked, empty when it is
+	Skipped string
+	Series  map[pow.PowType][]DiffPoint
+}
+
+// NewDifficultyVerify keeps the headers of the verified blocks in db, nil
+// keeps them in memory only.
+func NewDifficultyVerify(setting *conf.Difficulty, db *check_db.CheckDB) *DifficultyVerify {
+	return &DifficultyVerify{
+		params: map[pow.PowType]*conf.Retarget{
+			pow.BLAKE2BD: &setting.Blake2bd,
+			pow.CUCKAROO: &setting.Cuckaroo,
+			pow.CUCKATOO: &setting.Cuckatoo,
+		},
+		db:      db,
+		history: make(map[pow.PowType][]*diffNode),
+		count:   make(map[pow.PowType]int64),
+		Series:  make(map[pow.PowType][]DiffPoint),
+	}
+}
+
+// resume rebuilds the windows of a run that goes on after order from the
+// headers in the database. It skips the retarget when they do not go back
+// to genesis.
+func (d *DifficultyVerify) resume(order uint64) error {
+	if from, ok := d.db.HeadersFrom(); !ok || from != 0 {
+		d.Skipped = "the database has no difficulty history back to genesis"
+		return nil
+	}
+	return d.load(order)
+}
+
+// load replaces the windows with the headers in the database up to order.
+func (d *DifficultyVerify) load(order uint64) error {
+	history := make(map[pow.PowType][]*diffNode)
+	count := make(map[pow.PowType]int64)
+	err := d.db.Headers(order, func(blockOrder uint64, h *check_db.Header) {
+		powType := pow.PowType(h.PowType)
+		params, ok := d.params[powType]
+		if !ok {
+			return
+		}
+		nodes := append(history[powType], &diffNode{order: blockOrder, timestamp: h.Timestamp, bits: h.Bits})
+		if keep := int(params.WindowSize*params.Windows + 1); len(nodes) > keep {
+			nodes = nodes[len(nodes)-keep:]
+		}
+		history[powType] = nodes
+		count[powType] = h.Count
+	})
+	if err != nil {
+		return fmt.Errorf("load difficulty history failed, %s", err.Error())
+	}
+	d.history, d.count = history, count
+	return nil
+}
+
+func (d *DifficultyVerify) verify(b *rpc.Block) error {
+	if b.Order == 0 || b.Pow == nil {
+		return nil
+	}
+	bits, err := pow.ParseBits(b.Bits)
+	if err != nil {
+		return fmt.Errorf("block order=%d, hash=%s %s.", b.Order, b.Hash, err.Error())
+	}
+	if b.Difficulty != bits {
+		return withKind("bits", fmt.Errorf("block order=%d, hash=%s, bits=%s, difficulty=%d disagree.", b.Order, b.Hash, b.Bits, b.Difficulty)).
+			value("bits", b.Bits).value("difficulty", fmt.Sprintf("%08x", b.Difficulty))
+	}
+	powType := pow.PowType(b.Pow.PowType)
+	params, ok := d.params[powType]
+	if !ok {
+		return fmt.Errorf("block order=%d, hash=%s has unknown pow type %d.", b.Order, b.Hash, b.Pow.PowType)
+	}
+	d.Series[powType] = append(d.Series[powType], DiffPoint{Order: b.Order, Difficulty: difficulty(powType, bits)})
+
+	history := d.history[powType]
+	var expected uint32
+	if len(history) != 0 && d.Skipped == "" {
+		expected, err = nextRequiredBits(powType, params, history, d.count[powType])
+		if err != nil {
+			return fmt.Errorf("block order=%d, hash=%s %s.", b.Order, b.Hash, err.Error())
+		}
+	}
+
+	history = append(history, &diffNode{order: b.Order, timestamp: b.Timestamp.Unix(), bits: bits})
+	if keep := int(params.WindowSize*params.Windows + 1); len(history) > keep {
+		history = history[len(history)-keep:]
+	}
+	d.history[powType] = history
+	d.count[powType]++
+	if d.db != nil {
+		h := &check_db.Header{PowType: int(powType), Timestamp: b.Timestamp.Unix(), Bits: bits, Count: d.count[powType]}
+		if err := d.db.SaveHeader(b.Order, h); err != nil {
+			return fmt.Errorf("block order=%d, hash=%s save header failed, %s.", b.Order, b.Hash, err.Error())
+		}
+	}
+
+	if expected != 0 && expected != bits {
+		return withKind("retarget", fmt.Errorf("find wrong difficulty block order=%d, hash=%s, pow=%s, bits=%08x, correct=%08x.",
+			b.Order, b.Hash, powType.String(), bits, expected)).
+			value("bits", fmt.Sprintf("%08x", bits)).value("correct", fmt.Sprintf("%08x", expected))
+	}
+	return nil
+}
+
+// rewind forgets the blocks after order, so they can be verified again.
+// The windows are trimmed to keep them bounded, a rollback deeper than
+// them is rebuilt from the headers in the database, which must be rolled
+// back first.
+func (d *DifficultyVerify) rewind(order uint64) error {
+	for powType, series := range d.Series {
+		keep := len(series)
+		for keep > 0 && series[keep-1].Order > order {
+			keep--
+		}
+		d.Series[powType] = series[:keep]
+	}
+	if d.db != nil && d.Skipped == "" {
+		return d.load(order)
+	}
+	for powType, history := range d.history {
+		keep := len(history)
+		for keep > 0 && history[keep-1].order > order {
+			keep--
+		}
+		d.count[powType] -= int64(len(history) - keep)
+		d.history[powType] = history[:keep]
+	}
+	return nil
+}
+
+// nextRequiredBits keeps the bits of the previous block of the algorithm
+// unless count is at a window boundary, where the weighted average timespan
+// of the recent windows is used to scale the previous target. Recent
+// windows weigh 2^alpha times more than the window before them.
+func nextRequiredBits(powType pow.PowType, params *conf.Retarget, history []*diffNode, count int64) (uint32, error) {
+	last := history[len(history)-1]
+	if params.WindowSize <= 0 || count%params.WindowSize != 0 {
+		return last.bits, nil
+	}
+	limit, err := pow.ParseBits(params.Limit)
+	if err != nil {
+		return 0, err
+	}
+
+	weightedSum, weightSum := big.NewInt(0), big.NewInt(0)
+	for i := int64(0); i < params.Windows; i++ {
+		end := int64(len(history)-1) - i*params.WindowSize
+		start := end - params.WindowSize
+		if start < 0 {
+			break
+		}
+		timespan := history[end].timestamp - history[start].timestamp
+		weight := new(big.Int).Lsh(big.NewInt(1), uint((params.Windows-i-1)*params.Alpha))
+		weightedSum.Add(weightedSum, new(big.Int).Mul(big.NewInt(timespan), weight))
+		weightSum.Add(weightSum, weight)
+	}
+	if weightSum.Sign() == 0 {
+		return last.bits, nil
+	}
+	timespan := weightedSum.Div(weightedSum, weightSum).Int64()
+	targetTimespan := params.TargetTime * params.WindowSize
+	if factor := params.Factor; factor > 0 {
+		if timespan < targetTimespan/factor {
+			timespan = targetTimespan / factor
+		} else if timespan > targetTimespan*factor {
+			timespan = targetTimespan * factor
+		}
+	}
+	if timespan <= 0 || targetTimespan <= 0 {
+		return last.bits, nil
+	}
+
+	old := pow.CompactToBig(last.bits)
+	next := new(big.Int)
+	limitBig := pow.CompactToBig(limit)
+	if powType == pow.BLAKE2BD {
+		// The bits are a target, slower blocks raise it
+		next.Mul(old, big.NewInt(timespan))
+		next.Div(next, big.NewInt(targetTimespan))
+		if next.Cmp(limitBig) > 0 {
+			next.Set(limitBig)
+		}
+	} else {
+		// The bits are a difficulty, slower blocks lower it
+		next.Mul(old, big.NewInt(targetTimespan))
+		next.Div(next, big.NewInt(timespan))
+		if next.Cmp(limitBig) < 0 {
+			next.Set(limitBig)
+		}
+	}
+	return pow.BigToCompact(next), nil
+}
+
+// difficulty converts bits to a comparable difficulty, blake2bd bits are a
+// target and the cuckoo bits are already a difficulty.
+func difficulty(powType pow.PowType, bits uint32) float64 {
+	n := pow.CompactToBig(bits)
+	if powType == pow.BLAKE2BD {
+		if n.Sign() <= 0 {
+			return 0
+		}
+		max := new(big.Int).Lsh(big.NewInt(1), 256)
+		n = max.Div(max, n.Add(n, big.NewInt(1)))
+	}
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return f
+}
+
+// SeriesReport summarizes the difficulty of every algorithm with at most
+// points samples.
+func (d *DifficultyVerify) SeriesReport(points int) string {
+	types := make([]int, 0, len(d.Series))
+	for powType := range d.Series {
+		types = append(types, int(powType))
+	}
+	sort.Ints(types)
+
+	rs := ""
+	for _, t := range types {
+		series := d.Series[pow.PowType(t)]
+		min, max := series[0].Difficulty, series[0].Difficulty
+		for _, p := range series {
+			if p.Difficulty < min {
+				min = p.Difficulty
+			}
+			if p.Difficulty > max {
+				max = p.Difficulty
+			}
+		}
+		rs += fmt.Sprintf("%s blocks=%d, min=%.0f, max=%.0f, last=%.0f\n",
+			pow.PowType(t).String(), len(series), min, max, series[len(series)-1].Difficulty)
+		step := 1
+		if points > 0 && len(series) > points {
+			step = (len(series) + points - 1) / points
+		}
+		for i := 0; i < len(series); i += step {
+			rs += fmt.Sprintf("  order=%d difficulty=%.0f\n", series[i].Order, series[i].Difficulty)
+		}
+	}
+	return rs
+}
@@ -0,0 +1,144 @@
+package check
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bCoder778/qitmeer_test/check/check_db"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/rpc"
+)
+
+func newTestFees(t *testing.T) *FeesVerify {
+	db, err := check_db.NewCheckDB("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &FeesVerify{db: db, Supply: make([]SupplyPoint, 0)}
+}
+
+func coinbaseTx(id string, amount uint64) rpc.Transaction {
+	return rpc.Transaction{Txid: id, Vin: []rpc.Vin{{Coinbase: "00"}}, Vout: []rpc.Vout{{Amount: amount}}}
+}
+
+func spendTx(id, from string, amount uint64) rpc.Transaction {
+	return rpc.Transaction{Txid: id, Vin: []rpc.Vin{{Txid: from}}, Vout: []rpc.Vout{{Amount: amount}}}
+}
+
+func TestFeesVerifyCommit(t *testing.T) {
+	f := newTestFees(t)
+	defer f.Close()
+
+	good := &rpc.Block{Order: 1, Hash: "b1", Txsvalid: true, Transactions: []rpc.Transaction{coinbaseTx("c1", 12e9)}}
+	if err := f.verify(good); err != nil {
+		t.Fatalf("good block: %v", err)
+	}
+	if f.db.LastBlockOrder() != 1 {
+		t.Fatalf("last order %d after a good block", f.db.LastBlockOrder())
+	}
+
+	// The fee of 1e9 is missing from the coinbase, the block is still
+	// applied in full
+	wrongFee := &rpc.Block{Order: 2, Hash: "b2", Txsvalid: true, Transactions: []rpc.Transaction{
+		coinbaseTx("c2", 12e9), spendTx("t2", "c1", 11e9)}}
+	err := f.verify(wrongFee)
+	if e := asFinding(err); e == nil || e.kind != "fee" {
+		t.Fatalf("wrong fee: got %v", err)
+	}
+	if f.db.LastBlockOrder() != 2 {
+		t.Fatalf("last order %d after a wrong fee", f.db.LastBlockOrder())
+	}
+	if utxo, err := f.db.GetUTXO("c1", 0); err != nil || utxo.Spent != "t2" {
+		t.Fatalf("spend of a wrong fee block not applied, %v %v", utxo, err)
+	}
+	if len(f.db.WrongList()) != 1 {
+		t.Fatalf("wrong fee not recorded")
+	}
+
+	// Spending an unknown output fails half way, nothing may be written
+	broken := &rpc.Block{Order: 3, Hash: "b3", Txsvalid: true, Transactions: []rpc.Transaction{
+		coinbaseTx("c3", 12e9), spendTx("t3", "missing", 1)}}
+	if err := f.verify(broken); err == nil || asFinding(err) != nil {
+		t.Fatalf("broken block: got %v", err)
+	}
+	if f.db.LastBlockOrder() != 2 {
+		t.Fatalf("last order %d after a broken block", f.db.LastBlockOrder())
+	}
+	if _, err := f.db.GetUTXO("c3", 0); err != base.ErrNotFound {
+		t.Fatalf("output of a broken block was written, %v", err)
+	}
+	stats, err := f.db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 12e9+11e9 || stats.Count != 2 {
+		t.Fatalf("stats total=%d count=%d after a broken block", stats.Total, stats.Count)
+	}
+}
+
+// The scripts are checked against the outputs the fees verification found
+// spent, a block it could not apply is not checked.
+func TestScriptsFollowFees(t *testing.T) {
+	f := newTestFees(t)
+	defer f.Close()
+	s := NewScriptVerify()
+	// a full serialized tx of one input with an empty signature script
+	oneInput := "01000000" + "01" + strings.Repeat("00", 36) + "ffffffff" + "00" + strings.Repeat("00", 12) + "01" + "00"
+	spend := func(id, from string) rpc.Transaction {
+		tx := spendTx(id, from, 12e9)
+		tx.Hex = oneInput
+		return tx
+	}
+
+	b1 := &rpc.Block{Order: 1, Hash: "b1", Txsvalid: true, Transactions: []rpc.Transaction{coinbaseTx("c1", 12e9)}}
+	if err := f.verify(b1); err != nil {
+		t.Fatal(err)
+	}
+	// t2 spends c1 and t3 spends t2 of the same block
+	b2 := &rpc.Block{Order: 2, Hash: "b2", Txsvalid: true, Transactions: []rpc.Transaction{
+		coinbaseTx("c2", 12e9), spend("t2", "c1"), spend("t3", "t2")}}
+	if err := f.verify(b2); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.spent) != 2 || f.spent[spentKey("c1", 0)].Amount != 12e9 || f.spent[spentKey("t2", 0)] == nil {
+		t.Fatalf("spent %v", f.spent)
+	}
+	// The outputs have no standard script, found they are skipped
+	if err := s.verify(b2, f.spent); err != nil || s.Skipped != 2 {
+		t.Fatalf("scripts skipped %d, %v", s.Skipped, err)
+	}
+
+	broken := &rpc.Block{Order: 3, Hash: "b3", Txsvalid: true, Transactions: []rpc.Transaction{
+		coinbaseTx("c3", 12e9), spend("t4", "t3"), spend("t5", "missing")}}
+	if err := f.verify(broken); err == nil {
+		t.Fatal("broken block applied")
+	}
+	if f.spent != nil {
+		t.Fatalf("spent %v of a block not applied", f.spent)
+	}
+	if err := s.verify(broken, f.spent); err != nil || s.Skipped != 2 {
+		t.Fatalf("scripts of a block not applied skipped %d, %v", s.Skipped, err)
+	}
+}
+
+func TestPruneSetting(t *testing.T) {
+	defer func(db conf.DB) { conf.Setting.DB = db }(conf.Setting.DB)
+	tests := []struct {
+		prune string
+		depth uint64
+		ok    bool
+	}{
+		{prune: "keep", ok: true},
+		{prune: "", ok: true},
+		{prune: "delete", depth: recheck_orders, ok: true},
+		{prune: "delete", depth: recheck_orders - 1},
+		{prune: "archive", depth: 0},
+	}
+	for _, test := range tests {
+		conf.Setting.Prune, conf.Setting.Depth = test.prune, test.depth
+		if _, err := PruneSetting(); (err == nil) != test.ok {
+			t.Errorf("prune %q depth %d: %v", test.prune, test.depth, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/history"
+)
+
+// findingError tags an error with the kind of finding it is and the field
+// values behind it. The kind is part of the finding id, so it must not
+// depend on versions or other values that change between runs.
+type findingError struct {
+	kind   string
+	fields []history.Field
+	// values are found on one node, nodeError places them in its column
+	values []history.Field
+	err    error
+}
+
+func (e *findingError) Error() string {
+	return e.err.Error()
+}
+
+func withKind(kind string, err error) *findingError {
+	return &findingError{kind: kind, err: err}
+}
+
+// diff adds a field whose values on the two nodes disagree.
+func (e *findingError) diff(name string, release, test interface{}) *findingError {
+	e.fields = append(e.fields, history.Field{Name: name, Release: fmt.Sprint(release), Test: fmt.Sprint(test)})
+	return e
+}
+
+// value adds a field found on the node the error is about.
+func (e *findingError) value(name string, v interface{}) *findingError {
+	e.values = append(e.values, history.Field{Name: name, Release: fmt.Sprint(v)})
+	return e
+}
+
+func asFinding(err error) *findingError {
+	var e *findingError
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
+
+// nodeError prefixes the error found on the blocks of one node with the
+// node, and adds the node to its kind.
+func nodeError(node, version string, err error) *findingError {
+	wrapped := withKind(node, fmt.Errorf("%s %s %s", node, version, err.Error()))
+	if e := asFinding(err); e != nil {
+		wrapped.kind = node + "-" + e.kind
+		wrapped.fields = append(wrapped.fields, e.fields...)
+		for _, v := range e.values {
+			if node == "test" {
+				v.Test, v.Release = v.Release, ""
+			}
+			wrapped.fields = append(wrapped.fields, v)
+		}
+	}
+	return wrapped
+}
+
+// nodeErrors reports what failed on either node. The callers run the
+// verifiers of both nodes on every block, so their state stays in step,
+// and a block failing on both nodes is one finding with both kinds.
+func (c *Check) nodeErrors(releaseErr, testErr error) error {
+	switch {
+	case releaseErr == nil && testErr == nil:
+		return nil
+	case testErr == nil:
+		return nodeError("release", c.releaseVer, releaseErr)
+	case releaseErr == nil:
+		return nodeError("test", c.testVer, testErr)
+	}
+	release, test := nodeError("release", c.releaseVer, releaseErr), nodeError("test", c.testVer, testErr)
+	both := withKind(release.kind+"+"+test.kind, fmt.Errorf("%s; %s", release.Error(), test.Error()))
+	both.fields = append(release.fields, test.fields...)
+	return both
+}
+
+func newFinding(validator string, order uint64, hash string, err error) history.Finding {
+	f := history.Finding{
+		Validator: validator,
+		Order:     order,
+		Hash:      hash,
+		Message:   err.Error(),
+	}
+	if e := asFinding(err); e != nil {
+		f.Kind = e.kind
+		f.Fields = append(e.fields, e.values...)
+	}
+	f.ID = history.FindingID(validator, order, hash, f.Kind)
+	return f
+}
@@ -0,0 +1,62 @@
+package check
+
+import (
+	"errors"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+func diffBlock(order uint64, bits string, difficulty uint32) *rpc.Block {
+	return &rpc.Block{
+		Order:      order,
+		Hash:       "h",
+		Bits:       bits,
+		Difficulty: difficulty,
+		Timestamp:  time.Unix(int64(order)*30, 0),
+		Pow:        &rpc.Pow{PowType: 0},
+	}
+}
+
+func TestNodeErrors(t *testing.T) {
+	c := &Check{releaseVer: "v1", testVer: "v2"}
+	if err := c.nodeErrors(nil, nil); err != nil {
+		t.Fatalf("no errors gave %v", err)
+	}
+	release := withKind("bits", errors.New("release broken")).value("bits", "1")
+	test := withKind("retarget", errors.New("test broken")).value("bits", "2")
+
+	if e := asFinding(c.nodeErrors(release, nil)); e == nil || e.kind != "release-bits" {
+		t.Fatalf("release only gave %+v", e)
+	}
+	if e := asFinding(c.nodeErrors(nil, test)); e == nil || e.kind != "test-retarget" {
+		t.Fatalf("test only gave %+v", e)
+	}
+	e := asFinding(c.nodeErrors(release, test))
+	if e == nil || e.kind != "release-bits+test-retarget" {
+		t.Fatalf("both gave %+v", e)
+	}
+	if !strings.Contains(e.Error(), "release v1 release broken") || !strings.Contains(e.Error(), "test v2 test broken") {
+		t.Fatalf("message %q misses a node", e.Error())
+	}
+	if len(e.fields) != 2 || e.fields[0].Release != "1" || e.fields[1].Test != "2" {
+		t.Fatalf("fields %+v", e.fields)
+	}
+}
+
+// A block failing on the release node must still reach the difficulty
+// verifier of the test node, or its windows fall behind.
+func TestVerifyDifficultyRunsBoth(t *testing.T) {
+	setting := &conf.Difficulty{Blake2bd: conf.Retarget{WindowSize: 10, Windows: 2}}
+	c := &Check{releaseDiff: NewDifficultyVerify(setting, nil), testDiff: NewDifficultyVerify(setting, nil)}
+
+	err := c.VerifyDifficulty(diffBlock(1, "1e0fffff", 0x1d0fffff), diffBlock(1, "1e0fffff", 0x1e0fffff))
+	if e := asFinding(err); e == nil || e.kind != "release-bits" {
+		t.Fatalf("got %v", err)
+	}
+	if got := c.testDiff.count[0]; got != 1 {
+		t.Fatalf("test verifier saw %d blocks, want 1", got)
+	}
+}
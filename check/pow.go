@@ -0,0 +1,89 @@
+package check
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/pow"
+	"github.com/bCoder778/qitmeer_test/rpc"
+)
+
+func (c *Check) VerifyPow(releaseBlock, testBlock *rpc.Block) error {
+	if powString(releaseBlock.Pow) != powString(testBlock.Pow) {
+		return withKind("mismatch", fmt.Errorf("block order=%d, relase %s %s, test %s %s.",
+			releaseBlock.Order, c.releaseVer, powString(releaseBlock.Pow), c.testVer, powString(testBlock.Pow))).
+			diff("pow", powString(releaseBlock.Pow), powString(testBlock.Pow))
+	}
+	return c.nodeErrors(verifyPow(releaseBlock), verifyPow(testBlock))
+}
+
+func verifyPow(b *rpc.Block) error {
+	// The genesis block is not mined
+	if b.Order == 0 {
+		return nil
+	}
+	if b.Pow == nil {
+		return fmt.Errorf("block order=%d, hash=%s has no pow.", b.Order, b.Hash)
+	}
+	bits, err := pow.ParseBits(b.Bits)
+	if err != nil {
+		return fmt.Errorf("block order=%d, hash=%s %s.", b.Order, b.Hash, err.Error())
+	}
+	proof := &pow.Proof{
+		Type:  pow.PowType(b.Pow.PowType),
+		Nonce: b.Pow.Nonce,
+		Hash:  b.Hash,
+	}
+	if b.Pow.ProofData != nil {
+		proof.EdgeBits = b.Pow.ProofData.EdgeBits
+		if proof.CircleNonces, err = pow.ParseCircleNonces(b.Pow.ProofData.CircleNonces); err != nil {
+			return fmt.Errorf("block order=%d, hash=%s %s.", b.Order, b.Hash, err.Error())
+		}
+	}
+	if b.Header != "" {
+		if proof.Header, err = pow.ParseHeader(b.Header); err != nil {
+			return fmt.Errorf("block order=%d, hash=%s %s.", b.Order, b.Hash, err.Error())
+		}
+		if err := compareHeader(proof, proof.Header); err != nil {
+			return fmt.Errorf("block order=%d, hash=%s %s.", b.Order, b.Hash, err.Error())
+		}
+	}
+	if err := pow.Verify(proof, bits); err != nil {
+		return fmt.Errorf("find wrong pow block order=%d, hash=%s, pow=%s, bits=%s, %s.",
+			b.Order, b.Hash, proof.Type.String(), b.Bits, err.Error())
+	}
+	return nil
+}
+
+// compareHeader makes sure the serialized header agrees with the decoded
+// fields of the block, so the proof is verified against what the node
+// actually stored.
+func compareHeader(proof *pow.Proof, header *pow.Header) error {
+	if header.Hash() != proof.Hash {
+		return fmt.Errorf("header hash=%s", header.Hash())
+	}
+	if header.PowType != proof.Type || header.Nonce != proof.Nonce {
+		return fmt.Errorf("header pow=%s, nonce=%d, block pow=%s, nonce=%d",
+			header.PowType.String(), header.Nonce, proof.Type.String(), proof.Nonce)
+	}
+	if proof.Type != pow.BLAKE2BD {
+		if header.EdgeBits != proof.EdgeBits {
+			return fmt.Errorf("header edgebits=%d, block edgebits=%d", header.EdgeBits, proof.EdgeBits)
+		}
+		for i := range proof.CircleNonces {
+			if i >= len(header.CircleNonces) || header.CircleNonces[i] != proof.CircleNonces[i] {
+				return fmt.Errorf("header circle nonces differ at %d", i)
+			}
+		}
+	}
+	return nil
+}
+
+func powString(p *rpc.Pow) string {
+	if p == nil {
+		return "pow=nil"
+	}
+	rs := fmt.Sprintf("pow=%s, type=%d, nonce=%d", p.PowName, p.PowType, p.Nonce)
+	if p.ProofData != nil {
+		rs += fmt.Sprintf(", edgebits=%d, circlenonces=%s", p.ProofData.EdgeBits, p.ProofData.CircleNonces)
+	}
+	return rs
+}
@@ -0,0 +1,240 @@
+package check
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCheck(t *testing.T) *Check {
+	diff := &conf.Difficulty{Blake2bd: conf.Retarget{WindowSize: 10, Windows: 2, TargetTime: 30, Limit: "2000ffff"}}
+	times := &conf.BlockTime{}
+	releaseVerify, testVerify := newTestFees(t), newTestFees(t)
+	return &Check{
+		releaseVerify: releaseVerify,
+		testVerify:    testVerify,
+		releaseDiff:   NewDifficultyVerify(diff, releaseVerify.db),
+		testDiff:      NewDifficultyVerify(diff, testVerify.db),
+		releaseTime:   NewTimestampVerify(times),
+		testTime:      NewTimestampVerify(times),
+		releaseScript: NewScriptVerify(),
+		testScript:    NewScriptVerify(),
+		stop:          make(chan bool),
+	}
+}
+
+func chainBlock(order uint64, hash string, txs ...rpc.Transaction) *rpc.Block {
+	return &rpc.Block{Order: order, Height: order, Hash: hash, Txsvalid: true, Bits: "1e0fffff", Difficulty: 0x1e0fffff,
+		Timestamp: time.Unix(int64(order)*30, 0), Pow: &rpc.Pow{}, Transactions: txs}
+}
+
+// apply verifies the blocks as a run that is not resumed does.
+func apply(t *testing.T, c *Check, blocks ...*rpc.Block) {
+	for _, b := range blocks {
+		if replayed, err := c.replay(b, b); err != nil || replayed {
+			t.Fatalf("order %d replayed=%v, %v", b.Order, replayed, err)
+		}
+		for _, err := range []error{c.VerifyFees(b, b), c.VerifyDifficulty(b, b), c.VerifyTimestamp(b, b)} {
+			if err != nil {
+				t.Fatalf("order %d: %v", b.Order, err)
+			}
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	earlier := newTestCheck(t)
+	defer earlier.Close()
+	b1 := chainBlock(1, "b1", coinbaseTx("c1", 12e9))
+	b2 := chainBlock(2, "b2", coinbaseTx("c2", 12e9), spendTx("t2", "c1", 12e9))
+	apply(t, earlier, chainBlock(0, "b0"), b1, b2)
+
+	// A later run on the kept databases
+	c := newTestCheck(t)
+	c.releaseVerify, c.testVerify = earlier.releaseVerify, earlier.testVerify
+	if start := c.StartOrder(); start != 0 {
+		t.Fatalf("start order %d", start)
+	}
+	for _, b := range []*rpc.Block{chainBlock(0, "b0"), b1} {
+		if replayed, err := c.replay(b, b); err != nil || !replayed {
+			t.Fatalf("order %d replayed=%v, %v", b.Order, replayed, err)
+		}
+	}
+	if c.testDiff.count[0] != 1 || len(c.testTime.recent) != 2 {
+		t.Fatalf("replayed blocks not in the windows")
+	}
+
+	// Order 2 changed, the block spends nothing now
+	b2x := chainBlock(2, "b2x", coinbaseTx("c2x", 12e9))
+	if replayed, err := c.replay(b2x, b2x); err != nil || replayed {
+		t.Fatalf("changed order replayed=%v, %v", replayed, err)
+	}
+	for _, f := range []*FeesVerify{c.releaseVerify, c.testVerify} {
+		if last := f.db.LastBlockOrder(); last != 1 {
+			t.Fatalf("last order %d after the rollback", last)
+		}
+		if utxo, err := f.db.GetUTXO("c1", 0); err != nil || utxo.Spent != "" {
+			t.Fatalf("spend of the changed block not rolled back, %+v %v", utxo, err)
+		}
+		if _, err := f.db.GetUTXO("c2", 0); err != base.ErrNotFound {
+			t.Fatalf("output of the changed block kept, %v", err)
+		}
+	}
+	apply(t, c, b2x)
+	if hash, _ := c.testVerify.db.BlockHash(2); hash != "b2x" {
+		t.Fatalf("hash of order 2 %q", hash)
+	}
+	if total := c.supply(c.testVerify); total != 2*12e9+6524293004366634 {
+		t.Fatalf("supply %d", total)
+	}
+}
+
+func TestReplayGenesisChanged(t *testing.T) {
+	c := newTestCheck(t)
+	defer c.Close()
+	apply(t, c, chainBlock(0, "b0"))
+	other := chainBlock(0, "other")
+	if _, err := c.replay(other, other); err == nil {
+		t.Fatal("a changed genesis was not reported")
+	}
+}
+
+func TestRewindWindows(t *testing.T) {
+	c := newTestCheck(t)
+	defer c.Close()
+	apply(t, c, chainBlock(0, "b0"), chainBlock(1, "b1", coinbaseTx("c1", 12e9)),
+		chainBlock(2, "b2", coinbaseTx("c2", 12e9)), chainBlock(3, "b3", coinbaseTx("c3", 12e9)))
+
+	if err := c.testDiff.rewind(1); err != nil {
+		t.Fatal(err)
+	}
+	c.testTime.rewind(1)
+	if c.testDiff.count[0] != 1 || len(c.testDiff.history[0]) != 1 || len(c.testDiff.Series[0]) != 1 {
+		t.Fatalf("difficulty window not rewound, count=%d", c.testDiff.count[0])
+	}
+	if len(c.testTime.recent) != 2 || c.testTime.mainHeight != 1 || len(c.testTime.Intervals) != 1 {
+		t.Fatalf("timestamp window not rewound, %d blocks, main height %d", len(c.testTime.recent), c.testTime.mainHeight)
+	}
+	if _, ok := c.testTime.orders["b2"]; ok {
+		t.Fatalf("hash of a rewound block kept")
+	}
+	// The rewound orders verify again
+	if err := c.testTime.verify(chainBlock(2, "b2x")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A rollback past the blocks kept in the difficulty windows rebuilds them
+// from the headers in the database.
+func TestRewindPastWindows(t *testing.T) {
+	blocks := []*rpc.Block{chainBlock(0, "b0")}
+	for order := uint64(1); order < 60; order++ {
+		blocks = append(blocks, chainBlock(order, fmt.Sprintf("b%d", order), coinbaseTx(fmt.Sprintf("c%d", order), 12e9)))
+	}
+	c := newTestCheck(t)
+	defer c.Close()
+	apply(t, c, blocks...)
+	want := newTestCheck(t)
+	defer want.Close()
+	apply(t, want, blocks[:20]...)
+
+	changed := chainBlock(20, "b20x", coinbaseTx("c20x", 12e9))
+	if replayed, err := c.replay(changed, changed); err != nil || replayed {
+		t.Fatalf("changed order replayed=%v, %v", replayed, err)
+	}
+	if !reflect.DeepEqual(c.testDiff.history, want.testDiff.history) || !reflect.DeepEqual(c.testDiff.count, want.testDiff.count) {
+		t.Fatalf("rewound window count=%v, want %v", c.testDiff.count, want.testDiff.count)
+	}
+	if len(c.testDiff.Series[0]) != 19 {
+		t.Fatalf("%d difficulty points after the rewind", len(c.testDiff.Series[0]))
+	}
+	apply(t, c, changed)
+}
+
+// A run on kept databases rebuilds the difficulty windows from the headers
+// of the earlier runs, it does not skip the retarget.
+func TestResumeDifficulty(t *testing.T) {
+	earlier := newTestCheck(t)
+	defer earlier.Close()
+	for _, f := range []*FeesVerify{earlier.releaseVerify, earlier.testVerify} {
+		if err := f.db.SetHeadersFrom(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocks := []*rpc.Block{chainBlock(0, "b0")}
+	for order := uint64(1); order < 130; order++ {
+		blocks = append(blocks, chainBlock(order, fmt.Sprintf("b%d", order), coinbaseTx(fmt.Sprintf("c%d", order), 12e9)))
+	}
+	apply(t, earlier, blocks...)
+
+	c := newTestCheck(t)
+	c.releaseVerify, c.testVerify = earlier.releaseVerify, earlier.testVerify
+	c.releaseDiff.db, c.testDiff.db = c.releaseVerify.db, c.testVerify.db
+	start := c.StartOrder()
+	if start != 130-recheck_orders {
+		t.Fatalf("start order %d", start)
+	}
+	if err := c.testDiff.resume(start - 1); err != nil || c.testDiff.Skipped != "" {
+		t.Fatalf("resume skipped=%q, %v", c.testDiff.Skipped, err)
+	}
+	for _, b := range blocks[start:] {
+		if replayed, err := c.replay(b, b); err != nil || !replayed {
+			t.Fatalf("order %d replayed=%v, %v", b.Order, replayed, err)
+		}
+	}
+	if !reflect.DeepEqual(c.testDiff.history, earlier.testDiff.history) || !reflect.DeepEqual(c.testDiff.count, earlier.testDiff.count) {
+		t.Fatalf("resumed window count=%v, want %v", c.testDiff.count, earlier.testDiff.count)
+	}
+}
+
+func TestResumeDifficultyWithoutHistory(t *testing.T) {
+	c := newTestCheck(t)
+	defer c.Close()
+	apply(t, c, chainBlock(0, "b0"))
+	// A database seeded from a snapshot keeps the headers after the seed
+	if err := c.testVerify.db.SetHeadersFrom(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.testDiff.resume(0); err != nil || c.testDiff.Skipped == "" {
+		t.Fatalf("retarget checked without history, %v", err)
+	}
+	if err := c.releaseDiff.resume(0); err != nil || c.releaseDiff.Skipped == "" {
+		t.Fatalf("retarget checked on a database kept before headers, %v", err)
+	}
+	if reason := c.retargetSkipped(); !strings.Contains(reason, "release") || !strings.Contains(reason, "test") {
+		t.Fatalf("skip reason %q", reason)
+	}
+}
+
+// The run covers the orders it verified, not the ones it replayed.
+func TestRunOrders(t *testing.T) {
+	earlier := newTestCheck(t)
+	defer earlier.Close()
+	blocks := []*rpc.Block{chainBlock(0, "b0")}
+	for order := uint64(1); order < 7; order++ {
+		blocks = append(blocks, chainBlock(order, fmt.Sprintf("b%d", order), coinbaseTx(fmt.Sprintf("c%d", order), 12e9)))
+	}
+	apply(t, earlier, blocks[:5]...)
+
+	c := newTestCheck(t)
+	c.releaseVerify, c.testVerify = earlier.releaseVerify, earlier.testVerify
+	c.releaseScript, c.testScript = NewScriptVerify(), NewScriptVerify()
+	releaseBlocks, testBlocks := make(chan *rpc.Block, len(blocks)), make(chan *rpc.Block, len(blocks))
+	for _, b := range blocks {
+		releaseBlocks <- b
+		testBlocks <- b
+	}
+	close(releaseBlocks)
+	close(testBlocks)
+	c.CheckNode(releaseBlocks, testBlocks)
+
+	run := c.Run()
+	if run.FirstOrder != 5 || run.LastOrder != 6 || run.ReleaseCount != 2 {
+		t.Fatalf("run orders %d to %d, %d blocks", run.FirstOrder, run.LastOrder, run.ReleaseCount)
+	}
+}
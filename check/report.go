@@ -0,0 +1,124 @@
+package check
+
+import (
+	"github.com/bCoder778/qitmeer_test/history"
+	"github.com/bCoder778/qitmeer_test/pow"
+	"github.com/bCoder778/qitmeer_test/progress"
+	"github.com/bCoder778/qitmeer_test/report"
+	"time"
+)
+
+const report_points = 500
+
+var block_validators = []string{"consistency", "fees", "scripts", "coinbase", "pow", "difficulty", "timestamp"}
+
+// Report builds the structured report of the run, changes may be nil when
+// there is no history.
+func (c *Check) Report(run *history.Run, changes *history.Changes) *report.Report {
+	r := &report.Report{
+		Run: report.Run{
+			ID:         run.ID,
+			Start:      time.Unix(run.Start, 0),
+			End:        time.Unix(run.End, 0),
+			Seconds:    run.End - run.Start,
+			FirstOrder: run.FirstOrder,
+			LastOrder:  run.LastOrder,
+			Canceled:   run.Canceled,
+		},
+		Nodes: []report.Node{
+			nodeReport("release", c.releaseVer, c.ReleaseCount, c.ReleaseUtxo, c.releaseVerify, c.releaseTime, c.releaseDiff, c.releaseTrack),
+			nodeReport("test", c.testVer, c.TestCount, c.TestUtxo, c.testVerify, c.testTime, c.testDiff, c.testTrack),
+		},
+		Findings: make([]report.Finding, 0, len(c.findings)),
+		Resolved: make([]report.Finding, 0),
+	}
+	if c.seed != nil {
+		r.Run.Snapshot = &report.Snapshot{Order: c.seed.Order, Sha256: c.seed.Sum}
+	}
+
+	issues := make(map[string]*history.Issue)
+	if changes != nil {
+		for _, list := range [][]*history.Issue{changes.New, changes.Recurring} {
+			for _, issue := range list {
+				issues[issue.ID] = issue
+			}
+		}
+		for _, issue := range changes.Resolved {
+			r.Resolved = append(r.Resolved, findingReport(issue.Finding, issue))
+		}
+	}
+	failed := make(map[string]uint64)
+	for _, f := range c.findings {
+		failed[f.Validator]++
+		r.Findings = append(r.Findings, findingReport(f, issues[f.ID]))
+	}
+
+	for _, name := range block_validators {
+		r.Validators = append(r.Validators, report.Validator{Name: name, Checked: c.ReleaseCount, Failed: failed[name]})
+	}
+	r.Validators = append(r.Validators, report.Validator{Name: "account", Checked: 1, Failed: failed["account"]})
+	for i := range r.Validators {
+		if r.Validators[i].Name == "difficulty" {
+			r.Validators[i].Note = c.retargetSkipped()
+		}
+		if r.Validators[i].Name == "scripts" {
+			r.Validators[i].Counters = map[string]uint64{
+				"releaseChecked": c.releaseScript.Checked,
+				"releaseSkipped": c.releaseScript.Skipped,
+				"testChecked":    c.testScript.Checked,
+				"testSkipped":    c.testScript.Skipped,
+			}
+		}
+	}
+	return r
+}
+
+func nodeReport(role, version string, blocks, utxo uint64, fees *FeesVerify, ts *TimestampVerify, diff *DifficultyVerify,
+	tracker *progress.Tracker) report.Node {
+	node := report.Node{Role: role, Version: version, Blocks: blocks, Utxo: utxo, Difficulty: make(map[string][]report.Point)}
+	if tracker != nil {
+		status := tracker.Status()
+		node.FetchRate, node.VerifyRate = status.FetchRate, status.VerifyRate
+	}
+
+	supply := make([]report.Point, len(fees.Supply))
+	for i, p := range fees.Supply {
+		supply[i] = report.Point{Order: p.Order, Value: float64(p.Total)}
+	}
+	node.Supply = report.Sample(supply, report_points)
+
+	intervals := make([]report.Point, len(ts.Intervals))
+	for i, p := range ts.Intervals {
+		intervals[i] = report.Point{Order: p.Order, Value: float64(p.Interval)}
+	}
+	node.Intervals = report.Sample(intervals, report_points)
+
+	for powType, series := range diff.Series {
+		points := make([]report.Point, len(series))
+		for i, p := range series {
+			points[i] = report.Point{Order: p.Order, Value: p.Difficulty}
+		}
+		node.Difficulty[pow.PowType(powType).String()] = report.Sample(points, report_points)
+	}
+	return node
+}
+
+func findingReport(f history.Finding, issue *history.Issue) report.Finding {
+	rf := report.Finding{
+		ID:        f.ID,
+		Validator: f.Validator,
+		Kind:      f.Kind,
+		Order:     f.Order,
+		Hash:      f.Hash,
+		Message:   f.Message,
+	}
+	for _, field := range f.Fields {
+		rf.Fields = append(rf.Fields, report.Field{Name: field.Name, Release: field.Release, Test: field.Test})
+	}
+	if issue != nil {
+		rf.Status = string(issue.Status)
+		rf.Acked = issue.Acked
+		rf.FirstRun = issue.FirstRun
+	}
+	return rf
+}
@@ -0,0 +1,78 @@
+package check
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/check/check_db"
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"github.com/bCoder778/qitmeer_test/script"
+	"strings"
+)
+
+// ScriptVerify executes the signature script of every input against the
+// script of the output it spends, outputs that are not standard templates
+// are skipped.
+type ScriptVerify struct {
+	Checked uint64
+	Skipped uint64
+}
+
+func NewScriptVerify() *ScriptVerify {
+	return &ScriptVerify{}
+}
+
+// verify checks the inputs of the block against spent, the outputs they
+// spend as the fees verification found them. Without them, when the block
+// could not be applied, there is nothing to check against and the block is
+// left to the fees finding.
+func (s *ScriptVerify) verify(b *rpc.Block, spent map[string]*check_db.UTXO) error {
+	if !b.Txsvalid || spent == nil {
+		return nil
+	}
+	errs := make([]string, 0)
+	for _, tx := range b.Transactions {
+		if tx.Duplicate || isCoinBase(&tx) {
+			continue
+		}
+		msg, err := script.ParseTx(tx.Hex)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("tx %s %s", tx.Txid, err.Error()))
+			continue
+		}
+		for i, vin := range tx.Vin {
+			if vin.Txid == "" {
+				continue
+			}
+			if err := s.verifyInput(msg, i, &vin, spent); err != nil {
+				errs = append(errs, fmt.Sprintf("tx %s input %d spending %s:%d %s", tx.Txid, i, vin.Txid, vin.Vout, err.Error()))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("find wrong script block order=%d, hash=%s, %s.", b.Order, b.Hash, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (s *ScriptVerify) verifyInput(msg *script.Tx, idx int, vin *rpc.Vin, spent map[string]*check_db.UTXO) error {
+	utxo, ok := spent[spentKey(vin.Txid, vin.Vout)]
+	if !ok {
+		return fmt.Errorf("spent output not found")
+	}
+	pkScript, err := hex.DecodeString(utxo.Script)
+	if err != nil {
+		return fmt.Errorf("invalid pubkey script, %s", err.Error())
+	}
+	switch script.GetScriptClass(pkScript) {
+	case script.PubKeyTy, script.PubKeyHashTy, script.MultiSigTy:
+	default:
+		s.Skipped++
+		return nil
+	}
+	sigScript, err := hex.DecodeString(vin.ScriptSig.Hex)
+	if err != nil {
+		return fmt.Errorf("invalid signature script, %s", err.Error())
+	}
+	s.Checked++
+	return script.NewEngine(sigScript, pkScript, msg, idx).Execute()
+}
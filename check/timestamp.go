@@ -0,0 +1,158 @@
+package check
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timestamp_window is how many of the latest blocks are remembered to find
+// the parents of a block.
+const timestamp_window = 2048
+
+type IntervalPoint struct {
+	Order    uint64
+	Interval int64
+}
+
+// TimestampVerify checks the median time past of the parents, the future
+// drift, monotonic main chain timestamps and the interval between orders.
+// The blocks are long confirmed when they are checked, so the drift is not
+// measured against the clock but against the children: a parent more than
+// MaxFuture ahead of a block mined on it was ahead of the time it was mined.
+type TimestampVerify struct {
+	setting    *conf.BlockTime
+	recent     []int64
+	heights    []uint64
+	firstOrder uint64
+	orders     map[string]uint64
+	hashes     []string
+	mainHeight uint64
+	mainTime   int64
+	Intervals  []IntervalPoint
+}
+
+func NewTimestampVerify(setting *conf.BlockTime) *TimestampVerify {
+	return &TimestampVerify{
+		setting:   setting,
+		recent:    make([]int64, 0),
+		heights:   make([]uint64, 0),
+		orders:    make(map[string]uint64),
+		hashes:    make([]string, 0),
+		Intervals: make([]IntervalPoint, 0),
+	}
+}
+
+func (t *TimestampVerify) verify(b *rpc.Block) error {
+	errs := make([]string, 0)
+	ts := b.Timestamp.Unix()
+	if t.setting.MaxFuture > 0 {
+		for _, parent := range b.ParentHash {
+			if pts, ok := t.timeOf(parent); ok && pts > ts+t.setting.MaxFuture {
+				errs = append(errs, fmt.Sprintf("parent hash=%s timestamp %s is %ds ahead of its child",
+					parent, time.Unix(pts, 0).String(), pts-ts))
+			}
+		}
+	}
+	if median, ok := t.parentsMedian(b.ParentHash); ok && ts <= median {
+		errs = append(errs, fmt.Sprintf("timestamp %s is not after parents median time %s",
+			b.Timestamp.String(), time.Unix(median, 0).String()))
+	}
+	if len(t.recent) == 0 || b.Height > t.mainHeight {
+		if len(t.recent) != 0 && ts < t.mainTime {
+			errs = append(errs, fmt.Sprintf("main chain height=%d timestamp %s is before height=%d timestamp %s",
+				b.Height, b.Timestamp.String(), t.mainHeight, time.Unix(t.mainTime, 0).String()))
+		}
+		t.mainHeight = b.Height
+		t.mainTime = ts
+	}
+	if len(t.recent) != 0 {
+		interval := ts - t.recent[len(t.recent)-1]
+		t.Intervals = append(t.Intervals, IntervalPoint{Order: b.Order, Interval: interval})
+		if t.setting.MaxInterval > 0 && interval > t.setting.MaxInterval {
+			errs = append(errs, fmt.Sprintf("anomalous interval %ds to previous order", interval))
+		}
+	}
+	t.push(b.Order, b.Hash, b.Height, ts)
+
+	if len(errs) != 0 {
+		return fmt.Errorf("find wrong timestamp block order=%d, hash=%s, %s.", b.Order, b.Hash, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// parentsMedian is the median timestamp of the MedianCount blocks up to the
+// latest ordered parent.
+func (t *TimestampVerify) parentsMedian(parents []string) (int64, bool) {
+	found := false
+	var latest uint64
+	for _, parent := range parents {
+		if order, ok := t.orders[parent]; ok && (!found || order > latest) {
+			latest = order
+			found = true
+		}
+	}
+	if !found || t.setting.MedianCount <= 0 {
+		return 0, false
+	}
+	end := int(latest-t.firstOrder) + 1
+	start := end - t.setting.MedianCount
+	if start < 0 {
+		start = 0
+	}
+	times := make([]int64, end-start)
+	copy(times, t.recent[start:end])
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2], true
+}
+
+// timeOf is the timestamp of a block in the window.
+func (t *TimestampVerify) timeOf(hash string) (int64, bool) {
+	order, ok := t.orders[hash]
+	if !ok {
+		return 0, false
+	}
+	return t.recent[order-t.firstOrder], true
+}
+
+func (t *TimestampVerify) push(order uint64, hash string, height uint64, ts int64) {
+	if len(t.recent) == 0 {
+		t.firstOrder = order
+	}
+	t.recent = append(t.recent, ts)
+	t.heights = append(t.heights, height)
+	t.hashes = append(t.hashes, hash)
+	t.orders[hash] = order
+	if len(t.recent) > timestamp_window {
+		delete(t.orders, t.hashes[0])
+		t.recent = t.recent[1:]
+		t.heights = t.heights[1:]
+		t.hashes = t.hashes[1:]
+		t.firstOrder++
+	}
+}
+
+// rewind forgets the blocks after order, so they can be verified again. The
+// main chain tip is found again among the blocks left in the window.
+func (t *TimestampVerify) rewind(order uint64) {
+	keep := len(t.recent)
+	for keep > 0 && t.firstOrder+uint64(keep-1) > order {
+		keep--
+		delete(t.orders, t.hashes[keep])
+	}
+	t.recent, t.heights, t.hashes = t.recent[:keep], t.heights[:keep], t.hashes[:keep]
+	t.mainHeight, t.mainTime = 0, 0
+	for i := range t.recent {
+		if i == 0 || t.heights[i] > t.mainHeight {
+			t.mainHeight, t.mainTime = t.heights[i], t.recent[i]
+		}
+	}
+	keep = len(t.Intervals)
+	for keep > 0 && t.Intervals[keep-1].Order > order {
+		keep--
+	}
+	t.Intervals = t.Intervals[:keep]
+}
@@ -0,0 +1,51 @@
+package check
+
+import (
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+func timeBlock(order uint64, hash string, ts int64, parents ...string) *rpc.Block {
+	return &rpc.Block{Order: order, Hash: hash, Height: order, Timestamp: time.Unix(ts, 0), ParentHash: parents}
+}
+
+func TestTimestampFuture(t *testing.T) {
+	v := NewTimestampVerify(&conf.BlockTime{MaxFuture: 100})
+	// long past blocks are not ahead of the clock, only of their children
+	if err := v.verify(timeBlock(0, "a", 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.verify(timeBlock(1, "b", 1500, "a")); err != nil {
+		t.Fatal(err)
+	}
+	// c and d are off the main chain, which may go back in time
+	c := timeBlock(2, "c", 1450, "b")
+	c.Height = 1
+	if err := v.verify(c); err != nil {
+		t.Fatalf("parent within maxfuture: %v", err)
+	}
+	d := timeBlock(3, "d", 1380, "b", "c")
+	d.Height = 1
+	err := v.verify(d)
+	if err == nil || !strings.Contains(err.Error(), "parent hash=b") || strings.Contains(err.Error(), "parent hash=c") {
+		t.Fatalf("got %v, want b ahead of its child", err)
+	}
+}
+
+func TestVerifyTimestampRunsBoth(t *testing.T) {
+	setting := &conf.BlockTime{MaxInterval: 10}
+	c := &Check{releaseTime: NewTimestampVerify(setting), testTime: NewTimestampVerify(setting)}
+	if err := c.VerifyTimestamp(timeBlock(0, "a", 0), timeBlock(0, "a", 0)); err != nil {
+		t.Fatal(err)
+	}
+	err := c.VerifyTimestamp(timeBlock(1, "b", 100, "a"), timeBlock(1, "b", 5, "a"))
+	if !strings.HasPrefix(err.Error(), "release ") {
+		t.Fatalf("got %v", err)
+	}
+	if len(c.testTime.recent) != 2 {
+		t.Fatalf("test verifier saw %d blocks, want 2", len(c.testTime.recent))
+	}
+}
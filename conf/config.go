@@ -12,7 +12,7 @@ const (
 	configFile = "config.toml"
 )
 
-var Setting *Config
+var Setting = &Config{}
 var once sync.Once
 
 func init() {
@@ -30,6 +30,14 @@ type Config struct {
 	Log         `toml:"log"`
 	Check       `toml:"check"`
 	Task        `toml:"task"`
+	Difficulty  `toml:"difficulty"`
+	BlockTime   `toml:"timestamp"`
+	DB          `toml:"db"`
+	History     `toml:"history"`
+	Report      `toml:"report"`
+	Notify      `toml:"notify"`
+	HTTP        `toml:"http"`
+	Progress    `toml:"progress"`
 	ReleaseNode Node `toml:"releasenode"`
 	TestNode    Node `toml:"testnode"`
 }
@@ -54,17 +62,119 @@ type Node struct {
 }
 
 type Check struct {
-	Order uint64 `toml:"order"`
+	Order    uint64 `toml:"order"`
+	FullScan bool   `toml:"fullscan"`
+	Snapshot string `toml:"snapshot"`
 }
 
+type Difficulty struct {
+	Blake2bd Retarget `toml:"blake2bd"`
+	Cuckaroo Retarget `toml:"cuckaroo"`
+	Cuckatoo Retarget `toml:"cuckatoo"`
+}
+
+// Retarget describes how one pow algorithm adjusts its difficulty, every
+// windowsize blocks of the algorithm the weighted timespan of the last
+// windows windows is compared with targettime*windowsize.
+type Retarget struct {
+	TargetTime int64  `toml:"targettime"`
+	WindowSize int64  `toml:"windowsize"`
+	Windows    int64  `toml:"windows"`
+	Alpha      int64  `toml:"alpha"`
+	Factor     int64  `toml:"factor"`
+	Limit      string `toml:"limit"`
+}
+
+// BlockTime bounds block timestamps, all durations are in seconds.
+type BlockTime struct {
+	MedianCount int   `toml:"mediancount"`
+	MaxFuture   int64 `toml:"maxfuture"`
+	MaxInterval int64 `toml:"maxinterval"`
+}
+
+type DB struct {
+	Backend string `toml:"backend"`
+	Prune   string `toml:"prune"`
+	Depth   uint64 `toml:"depth"`
+}
+
+// History is where the record of every run is kept.
+type History struct {
+	Path string `toml:"path"`
+}
+
+// Report is where the reports of every run are written, JUnit is an extra
+// fixed path for the JUnit XML report.
+type Report struct {
+	Dir   string `toml:"dir"`
+	JUnit string `toml:"junit"`
+}
+
+// Notify routes notifications to sinks, every sink gets the messages of
+// its severity and above. Findings beyond rate a minute wait for a digest,
+// with digest seconds set they are only sent as digests, and cap bounds
+// the messages of a run.
+type Notify struct {
+	Rate   int    `toml:"rate"`
+	Digest int64  `toml:"digest"`
+	Cap    int    `toml:"cap"`
+	Sinks  []Sink `toml:"sink"`
+}
+
+// Sink is smtp, using the email settings, webhook, posting json to url, or
+// file, appending to path or writing to stdout.
+type Sink struct {
+	Type     string `toml:"type"`
+	Severity string `toml:"severity"`
+	URL      string `toml:"url"`
+	Path     string `toml:"path"`
+}
+
+// Progress is how a run reports its progress, a log line every
+// loginterval seconds and a notification when the verified blocks of a
+// node pass one of the milestones, in percent. No milestones, no
+// notifications.
+type Progress struct {
+	LogInterval int64 `toml:"loginterval"`
+	Milestones  []int `toml:"milestones"`
+}
+
+// HTTP is the address the daemon serves the dashboard, /metrics and the
+// control /api on, empty for none. Token, when set, is required to start
+// and cancel runs.
+type HTTP struct {
+	Listen string `toml:"listen"`
+	Token  string `toml:"token"`
+}
+
+// Task is when the tests run, on the cron schedule when it is set and
+// every interval seconds from start otherwise. Times are in timezone,
+// local when empty. Missed is skip or catchup, what to do with the runs
+// whose time passed while the previous run was still going.
 type Task struct {
 	Start     string `toml:"start"`
 	Interval  int64  `toml:"interval"`
+	Cron      string `toml:"cron"`
+	Timezone  string `toml:"timezone"`
+	Missed    string `toml:"missed"`
 	Timestamp int64
+	Location  *time.Location `toml:"-"`
 }
 
 func decodeStart() {
-	t, err := time.ParseInLocation("2006-01-02 15:04:05", Setting.Start, time.Local)
+	Setting.Location = time.Local
+	if Setting.Timezone != "" {
+		loc, err := time.LoadLocation(Setting.Timezone)
+		if err != nil {
+			fmt.Printf("decode timezone %s failed!, err:%s\n", Setting.Timezone, err.Error())
+		} else {
+			Setting.Location = loc
+		}
+	}
+	if Setting.Start == "" {
+		return
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", Setting.Start, Setting.Location)
 	if err != nil {
 		fmt.Printf("decode start %s failed!, err:%s\n", Setting.Start, err.Error())
 	}
@@ -0,0 +1,308 @@
+package dashboard
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/history"
+	"github.com/bCoder778/qitmeer_test/rpc"
+	"github.com/bCoder778/qitmeer_test/test"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dashboard_runs is how many of the latest runs the front page lists.
+const dashboard_runs = 20
+
+// The dashboard is read only, runs are started and canceled through the
+// control api, which the page calls for the live progress.
+//
+//	GET /                   recent runs and the progress of the current one
+//	GET /findings?run=ID    findings of a run, the latest by default,
+//	                        filtered by validator, node, from and to order
+//	GET /blocks/{order}     the block on both nodes side by side
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("/", index)
+	mux.HandleFunc("/findings", findings)
+	mux.HandleFunc("/blocks/", block)
+}
+
+type indexPage struct {
+	Progress test.Progress
+	Runs     []*history.Run
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	h, err := test.OpenHistory()
+	if err != nil {
+		fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	runs, err := h.Runs()
+	h.Close()
+	if err != nil {
+		fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	page := &indexPage{Progress: test.CurrentProgress(), Runs: make([]*history.Run, 0, dashboard_runs)}
+	for i := len(runs) - 1; i >= 0 && len(page.Runs) < dashboard_runs; i-- {
+		page.Runs = append(page.Runs, runs[i])
+	}
+	render(w, "index", page)
+}
+
+// Filter selects the findings of a run. Node is release or test for the
+// findings about one node, both for the disagreements between the nodes,
+// and empty for all of them.
+type Filter struct {
+	Run       uint64
+	Validator string
+	Node      string
+	From      string
+	To        string
+}
+
+type findingsPage struct {
+	Filter     Filter
+	Run        *history.Run
+	Runs       []uint64
+	Validators []string
+	Findings   []history.Finding
+}
+
+func findings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := Filter{Validator: q.Get("validator"), Node: q.Get("node"), From: q.Get("from"), To: q.Get("to")}
+	from, err := parseOrder(filter.From, 0)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	to, err := parseOrder(filter.To, ^uint64(0))
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	switch filter.Node {
+	case "", "release", "test", "both":
+	default:
+		fail(w, http.StatusBadRequest, fmt.Errorf("node must be release, test or both"))
+		return
+	}
+
+	h, err := test.OpenHistory()
+	if err != nil {
+		fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	page := &findingsPage{Filter: filter, Findings: make([]history.Finding, 0)}
+	last := h.LastID()
+	for id := last; id > 0 && len(page.Runs) < dashboard_runs; id-- {
+		page.Runs = append(page.Runs, id)
+	}
+	filter.Run, err = parseOrder(q.Get("run"), last)
+	if err == nil && filter.Run != 0 {
+		if page.Run, err = h.Get(filter.Run); err != nil {
+			h.Close()
+			fail(w, http.StatusNotFound, fmt.Errorf("run %d not found", filter.Run))
+			return
+		}
+	}
+	h.Close()
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	page.Filter.Run = filter.Run
+	if filter.Run == 0 {
+		render(w, "findings", page)
+		return
+	}
+
+	validators := make(map[string]bool)
+	for _, f := range page.Run.Findings {
+		validators[f.Validator] = true
+		if filter.Validator != "" && f.Validator != filter.Validator {
+			continue
+		}
+		if !onNode(f.Kind, filter.Node) {
+			continue
+		}
+		if f.Order < from || f.Order > to {
+			continue
+		}
+		page.Findings = append(page.Findings, f)
+	}
+	for name := range validators {
+		page.Validators = append(page.Validators, name)
+	}
+	sort.Strings(page.Validators)
+	render(w, "findings", page)
+}
+
+// onNode tells if a finding is about the node. The kinds of the findings
+// about one node carry its name as prefix, a block failing on both nodes
+// has the kinds of both. The others, on both, are disagreements between
+// the nodes.
+func onNode(kind, node string) bool {
+	release := strings.HasPrefix(kind, "release-")
+	test := strings.HasPrefix(kind, "test-") || strings.Contains(kind, "+test-")
+	switch node {
+	case "release":
+		return release
+	case "test":
+		return test
+	case "both":
+		return !release && !test
+	}
+	return true
+}
+
+func parseOrder(value string, def uint64) (uint64, error) {
+	if value == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad number %q", value)
+	}
+	return v, nil
+}
+
+type blockPage struct {
+	Order    uint64
+	Release  string
+	Test     string
+	Rows     []blockRow
+	Issues   []*history.Issue
+	Problems []string
+}
+
+type blockRow struct {
+	Name    string
+	Release string
+	Test    string
+	Differ  bool
+}
+
+func block(w http.ResponseWriter, r *http.Request) {
+	order, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/blocks/"), 10, 64)
+	if err != nil {
+		fail(w, http.StatusBadRequest, fmt.Errorf("bad block order, %s", err.Error()))
+		return
+	}
+	page := &blockPage{Order: order}
+	release := test.NewClient(&conf.Setting.ReleaseNode, "release")
+	tester := test.NewClient(&conf.Setting.TestNode, "test")
+	releaseBlock, ok := release.GetBlock(order)
+	if !ok {
+		releaseBlock = nil
+		page.Problems = append(page.Problems, fmt.Sprintf("release node has no block %d", order))
+	}
+	testBlock, ok := tester.GetBlock(order)
+	if !ok {
+		testBlock = nil
+		page.Problems = append(page.Problems, fmt.Sprintf("test node has no block %d", order))
+	}
+	if info, err := release.GetNodeInfo(); err == nil {
+		page.Release = info.Buildversion
+	}
+	if info, err := tester.GetNodeInfo(); err == nil {
+		page.Test = info.Buildversion
+	}
+	page.Rows = blockRows(releaseBlock, testBlock)
+
+	if h, err := test.OpenHistory(); err == nil {
+		if issues, err := h.Issues(); err == nil {
+			for _, issue := range issues {
+				if issue.Order == order && issue.Order != 0 {
+					page.Issues = append(page.Issues, issue)
+				}
+			}
+		}
+		h.Close()
+	}
+	render(w, "block", page)
+}
+
+// blockRows lines up the fields of the two blocks, a nil block leaves its
+// column empty. Confirmations are left out, they differ all the time.
+func blockRows(release, tester *rpc.Block) []blockRow {
+	values := func(b *rpc.Block) []string {
+		if b == nil {
+			return nil
+		}
+		vs := []string{
+			b.Hash,
+			fmt.Sprint(b.Height),
+			fmt.Sprint(b.Weight),
+			fmt.Sprint(b.Txsvalid),
+			fmt.Sprint(b.Version),
+			b.TxRoot,
+			b.StateRoot,
+			b.Bits,
+			fmt.Sprint(b.Difficulty),
+			b.Timestamp.UTC().Format(time.RFC3339),
+			strings.Join(b.ParentHash, " "),
+			strings.Join(b.ChildrenHash, " "),
+		}
+		if b.Pow != nil {
+			vs = append(vs, b.Pow.PowName, fmt.Sprint(b.Pow.Nonce))
+		} else {
+			vs = append(vs, "", "")
+		}
+		vs = append(vs, fmt.Sprint(len(b.Transactions)))
+		for _, tx := range b.Transactions {
+			vs = append(vs, tx.Txid)
+		}
+		return vs
+	}
+	names := []string{"hash", "height", "weight", "txsvalid", "version", "txRoot", "stateRoot", "bits",
+		"difficulty", "timestamp", "parents", "children", "pow", "nonce", "transactions"}
+	rvs, tvs := values(release), values(tester)
+	count := len(rvs)
+	if len(tvs) > count {
+		count = len(tvs)
+	}
+	rows := make([]blockRow, 0, count)
+	for i := 0; i < count; i++ {
+		row := blockRow{}
+		if i < len(names) {
+			row.Name = names[i]
+		} else {
+			row.Name = fmt.Sprintf("tx %d", i-len(names))
+		}
+		if i < len(rvs) {
+			row.Release = rvs[i]
+		}
+		if i < len(tvs) {
+			row.Test = tvs[i]
+		}
+		row.Differ = row.Release != row.Test && release != nil && tester != nil
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// render executes the page before writing it, so a template error is not
+// sent half way through a page.
+func render(w http.ResponseWriter, name string, page interface{}) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, page); err != nil {
+		fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+func fail(w http.ResponseWriter, status int, err error) {
+	http.Error(w, err.Error(), status)
+}
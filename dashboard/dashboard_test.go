@@ -0,0 +1,129 @@
+package dashboard
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/history"
+	"github.com/bCoder778/qitmeer_test/rpc"
+)
+
+func TestOnNode(t *testing.T) {
+	tests := []struct {
+		kind                string
+		release, test, both bool
+	}{
+		{kind: "release-bits", release: true},
+		{kind: "test-retarget", test: true},
+		{kind: "release-bits+test-retarget", release: true, test: true},
+		{kind: "hash", both: true},
+		{kind: "fee", both: true},
+		// a kind naming a node elsewhere is not about that node
+		{kind: "latest-test-x", both: true},
+	}
+	for _, test := range tests {
+		if onNode(test.kind, "release") != test.release || onNode(test.kind, "test") != test.test ||
+			onNode(test.kind, "both") != test.both || !onNode(test.kind, "") {
+			t.Errorf("%s: release=%v test=%v both=%v", test.kind,
+				onNode(test.kind, "release"), onNode(test.kind, "test"), onNode(test.kind, "both"))
+		}
+	}
+}
+
+func TestFindingsFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { conf.Setting.History.Path = path }(conf.Setting.History.Path)
+	conf.Setting.History.Path = filepath.Join(dir, "history_db")
+
+	h, err := history.Open(conf.Setting.History.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finding := func(validator string, order uint64, kind string) history.Finding {
+		return history.Finding{ID: history.FindingID(validator, order, "h", kind), Validator: validator, Order: order, Hash: "h", Kind: kind,
+			Message: "msg-" + validator}
+	}
+	_, err = h.Add(&history.Run{FirstOrder: 0, LastOrder: 20, ReleaseCount: 21, Findings: []history.Finding{
+		finding("fees", 1, "release-fee"),
+		finding("difficulty", 5, "test-retarget"),
+		finding("consistency", 9, "hash"),
+		finding("pow", 20, "release-pow+test-pow"),
+	}})
+	h.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := []string{"msg-fees", "msg-difficulty", "msg-consistency", "msg-pow"}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: all},
+		{query: "run=1&from=5&to=9", want: all[1:3]},
+		{query: "from=9", want: all[2:]},
+		{query: "to=1", want: all[:1]},
+		{query: "from=21", want: nil},
+		{query: "validator=difficulty", want: all[1:2]},
+		{query: "node=release", want: []string{all[0], all[3]}},
+		{query: "node=test", want: []string{all[1], all[3]}},
+		{query: "node=both", want: all[2:3]},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		findings(w, httptest.NewRequest(http.MethodGet, "/findings?"+test.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, %s", test.query, w.Code, w.Body.String())
+		}
+		want := make(map[string]bool)
+		for _, msg := range test.want {
+			want[msg] = true
+		}
+		for _, msg := range all {
+			if got := strings.Contains(w.Body.String(), msg); got != want[msg] {
+				t.Errorf("%s: %s shown=%v", test.query, msg, got)
+			}
+		}
+	}
+
+	for _, query := range []string{"from=x", "to=-1", "node=other", "run=x"} {
+		w := httptest.NewRecorder()
+		findings(w, httptest.NewRequest(http.MethodGet, "/findings?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	findings(w, httptest.NewRequest(http.MethodGet, "/findings?run=7", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing run: status %d", w.Code)
+	}
+}
+
+func TestBlockRows(t *testing.T) {
+	release := &rpc.Block{Hash: "a", Bits: "1d00ffff", Transactions: []rpc.Transaction{{Txid: "t1"}}}
+	tester := &rpc.Block{Hash: "a", Bits: "1d00fffe", Transactions: []rpc.Transaction{{Txid: "t1"}, {Txid: "t2"}}}
+	rows := blockRows(release, tester)
+	differ := make(map[string]bool)
+	for _, row := range rows {
+		differ[row.Name] = row.Differ
+	}
+	if differ["hash"] || !differ["bits"] || !differ["transactions"] || differ["tx 0"] || !differ["tx 1"] {
+		t.Fatalf("rows %+v", rows)
+	}
+	for _, row := range blockRows(release, nil) {
+		if row.Differ || row.Test != "" {
+			t.Fatalf("row %+v with a missing block", row)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package dashboard
+
+import (
+	"html/template"
+	"time"
+)
+
+var templates = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"unix": func(sec int64) string {
+		if sec == 0 {
+			return ""
+		}
+		return time.Unix(sec, 0).Format("2006-01-02 15:04:05")
+	},
+	"seconds": func(start, end int64) int64 {
+		return end - start
+	},
+}).Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.}}</title>
+<style>
+body{font-family:Helvetica,Arial,sans-serif;font-size:14px;color:#222;margin:16px}
+nav a{margin-right:16px}
+table{border-collapse:collapse;margin:8px 0 16px}
+th{background:#eee;text-align:left}
+th,td{padding:4px 8px;vertical-align:top}
+td.num{text-align:right}
+code,.mono{font-family:monospace}
+.muted{color:#555}
+.bad{color:#c00;font-weight:bold}
+tr.differ{background:#fdd}
+form label{margin-right:12px}
+</style></head>
+<body>
+<nav><a href="/">Runs</a><a href="/findings">Findings</a><a href="/api/reports">Reports</a><a href="/metrics">Metrics</a></nav>
+<h2>{{.}}</h2>
+{{end}}
+
+{{define "foot"}}</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "head" "Qitmeer test"}}
+<h3>Current run</h3>
+<div id="progress">{{with .Progress}}{{if .Running}}Running since {{unix .Start}}, order {{.Order}} of {{.FirstOrder}} to {{.LastOrder}}, {{.Findings}} findings{{if .Canceled}}, canceling{{end}}.{{range .Nodes}}<div class="muted">{{.String}}</div>{{end}}{{else}}<span class="muted">No run in progress.</span>{{end}}{{end}}</div>
+<script>
+function refresh() {
+	fetch("/api/progress").then(function(r) { return r.json() }).then(function(p) {
+		var el = document.getElementById("progress");
+		if (!p.running) {
+			el.innerHTML = '<span class="muted">No run in progress.</span>';
+			return;
+		}
+		var span = p.lastOrder - p.firstOrder, done = span > 0 ? (p.order - p.firstOrder) * 100 / span : 0;
+		el.textContent = "Running since " + new Date(p.start * 1000).toLocaleString() + ", order " + p.order +
+			" of " + p.firstOrder + " to " + p.lastOrder + " (" + done.toFixed(1) + "%), " + p.findings + " findings" +
+			(p.canceled ? ", canceling" : "") + ".";
+		(p.nodes || []).forEach(function(n) {
+			var line = document.createElement("div");
+			line.className = "muted";
+			line.textContent = n.node + " fetched " + n.fetchedOrder + ", verified " + n.order + " (" + n.percent.toFixed(2) +
+				"%), fetch " + n.fetchRate.toFixed(2) + " blocks/s, verify " + n.verifyRate.toFixed(2) + " blocks/s, eta " +
+				(n.eta < 0 ? "unknown" : n.eta + "s");
+			el.appendChild(line);
+		});
+	});
+}
+setInterval(refresh, 2000);
+</script>
+
+<h3>Recent runs</h3>
+<table>
+<tr><th>Run</th><th>Start</th><th>Seconds</th><th>Release</th><th>Test</th><th>Orders</th><th>Findings</th></tr>
+{{range .Runs}}<tr><td><a href="/findings?run={{.ID}}">#{{.ID}}</a></td><td>{{unix .Start}}</td><td class="num">{{seconds .Start .End}}</td><td>{{.ReleaseVersion}}</td><td>{{.TestVersion}}</td><td>{{.FirstOrder}} to {{.LastOrder}}{{if .Canceled}} <span class="bad">canceled</span>{{end}}</td><td class="num{{if .Findings}} bad{{end}}">{{len .Findings}}</td></tr>
+{{else}}<tr><td colspan="7" class="muted">No runs yet.</td></tr>
+{{end}}</table>
+{{template "foot"}}{{end}}
+
+{{define "findings"}}{{template "head" "Findings"}}
+<form method="get" action="/findings">
+<label>Run <select name="run">{{$run := .Filter.Run}}{{range .Runs}}<option value="{{.}}"{{if eq . $run}} selected{{end}}>#{{.}}</option>{{end}}</select></label>
+<label>Validator <select name="validator"><option value="">all</option>{{$v := .Filter.Validator}}{{range .Validators}}<option{{if eq . $v}} selected{{end}}>{{.}}</option>{{end}}</select></label>
+<label>Node <select name="node">{{$n := .Filter.Node}}<option value="">all</option><option value="release"{{if eq $n "release"}} selected{{end}}>release</option><option value="test"{{if eq $n "test"}} selected{{end}}>test</option><option value="both"{{if eq $n "both"}} selected{{end}}>disagreements</option></select></label>
+<label>Orders <input name="from" size="8" value="{{.Filter.From}}"> to <input name="to" size="8" value="{{.Filter.To}}"></label>
+<input type="submit" value="Filter">
+</form>
+{{with .Run}}<p class="muted">Run #{{.ID}}, {{unix .Start}}, release {{.ReleaseVersion}}, test {{.TestVersion}}, orders {{.FirstOrder}} to {{.LastOrder}}{{if .Canceled}}, canceled{{end}}, {{len .Findings}} findings in total.</p>{{end}}
+<table>
+<tr><th>Order</th><th>Validator</th><th>Kind</th><th>Message</th><th>Fields</th></tr>
+{{range .Findings}}<tr><td>{{if .Order}}<a href="/blocks/{{.Order}}">{{.Order}}</a>{{end}}</td><td>{{.Validator}}</td><td>{{.Kind}}</td><td>{{.Message}}<br><span class="muted">[{{.ID}}]</span></td><td>{{range .Fields}}<div class="mono">{{.Name}}: {{.Release}} / {{.Test}}</div>{{end}}</td></tr>
+{{else}}<tr><td colspan="5" class="muted">No findings.</td></tr>
+{{end}}</table>
+{{template "foot"}}{{end}}
+
+{{define "block"}}{{template "head" (printf "Block %d" .Order)}}
+{{range .Problems}}<p class="bad">{{.}}</p>{{end}}
+<table>
+<tr><th>Field</th><th>Release {{.Release}}</th><th>Test {{.Test}}</th></tr>
+{{range .Rows}}<tr{{if .Differ}} class="differ"{{end}}><td>{{.Name}}</td><td class="mono">{{.Release}}</td><td class="mono">{{.Test}}</td></tr>
+{{end}}</table>
+<h3>Issues at this order</h3>
+<table>
+<tr><th>Issue</th><th>Validator</th><th>Kind</th><th>Status</th><th>Runs</th><th>Message</th></tr>
+{{range .Issues}}<tr><td class="mono">{{.ID}}</td><td>{{.Validator}}</td><td>{{.Kind}}</td><td>{{.Status}}{{if .Acked}}, acknowledged{{end}}</td><td>{{.FirstRun}} to {{.LastRun}}</td><td>{{.Message}}</td></tr>
+{{else}}<tr><td colspan="6" class="muted">None.</td></tr>
+{{end}}</table>
+{{template "foot"}}{{end}}
+`))
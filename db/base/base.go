@@ -2,35 +2,23 @@ package base
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
-	"github.com/btcsuite/goleveldb/leveldb"
-	"github.com/btcsuite/goleveldb/leveldb/iterator"
-	"github.com/btcsuite/goleveldb/leveldb/opt"
-	"github.com/btcsuite/goleveldb/leveldb/util"
 )
 
+// Base adds buckets, plain key prefixes, on top of a Store.
 type Base struct {
-	db *leveldb.DB
+	db Store
 }
 
-func Open(path string) (*Base, error) {
-	var err error
-	opts := &opt.Options{
-		OpenFilesCacheCapacity: 16,
-		Strict:                 opt.DefaultStrict,
-		Compression:            opt.NoCompression,
-		BlockCacheCapacity:     8 * opt.MiB,
-		WriteBuffer:            4 * opt.MiB,
-	}
-	b := &Base{}
-	if b.db, err = leveldb.OpenFile(path, opts); err != nil {
-		if b.db, err = leveldb.RecoverFile(path, nil); err != nil {
-			return nil, errors.New(fmt.Sprintf(`err while recoverfile %s : %s`, path, err.Error()))
-		}
+func New(store Store) *Base {
+	return &Base{db: store}
+}
 
+func Open(path string) (*Base, error) {
+	store, err := OpenLevelDB(path)
+	if err != nil {
+		return nil, err
 	}
-	return b, nil
+	return New(store), nil
 }
 
 func (b *Base) Close() error {
@@ -38,19 +26,19 @@ func (b *Base) Close() error {
 }
 
 func (b *Base) Put(key []byte, value []byte) error {
-	return b.db.Put(key, value, nil)
+	return b.db.Put(key, value)
 }
 
 func (b *Base) Delete(key []byte) error {
-	return b.db.Delete(key, nil)
+	return b.db.Delete(key)
 }
 
 func (b *Base) Get(key []byte) ([]byte, error) {
-	return b.db.Get(key, nil)
+	return b.db.Get(key)
 }
 
 func (b *Base) Has(key []byte) (bool, error) {
-	_, err := b.db.Get(key, nil)
+	_, err := b.db.Get(key)
 	if err != nil {
 		return false, err
 	}
@@ -58,23 +46,32 @@ func (b *Base) Has(key []byte) (bool, error) {
 }
 
 func (b *Base) PutInBucket(bucket string, key, value []byte) error {
-	return b.db.Put(Key(bucket, key), value, nil)
+	return b.db.Put(Key(bucket, key), value)
 }
 
 func (b *Base) GetFromBucket(bucket string, key []byte) ([]byte, error) {
-	return b.db.Get(Key(bucket, key), nil)
+	return b.db.Get(Key(bucket, key))
+}
+
+// NewBatch creates a batch whose writes are applied atomically by Write.
+func (b *Base) NewBatch() *Batch {
+	return &Batch{batch: b.db.NewBatch()}
+}
+
+func (b *Base) Write(batch *Batch) error {
+	return b.db.Write(batch.batch)
 }
 
 func (b *Base) Clear(bucket string) {
 	rs := b.Foreach(bucket)
 	for key, _ := range rs {
-		b.db.Delete([]byte(key), nil)
+		b.db.Delete([]byte(key))
 	}
 }
 
 func (b *Base) Foreach(bucket string) map[string][]byte {
 	rs := make(map[string][]byte)
-	iter := b.db.NewIterator(util.BytesPrefix(bytes.Join([][]byte{[]byte(bucket), []byte("-")}, []byte{})), nil)
+	iter := b.db.NewIterator(Prefix(bucket))
 	defer iter.Release()
 
 	// Iter will affect RLP decoding and reallocate memory to value
@@ -86,8 +83,8 @@ func (b *Base) Foreach(bucket string) map[string][]byte {
 	return rs
 }
 
-func (b *Base) Iter(bucket string) iterator.Iterator {
-	return b.db.NewIterator(util.BytesPrefix(bytes.Join([][]byte{[]byte(bucket), []byte("-")}, []byte{})), nil)
+func (b *Base) Iter(bucket string) Iterator {
+	return b.db.NewIterator(Prefix(bucket))
 }
 
 func Key(bucket string, key []byte) []byte {
@@ -102,3 +99,31 @@ func Prefix(bucket string) []byte {
 func LeafKeyToKey(bucket string, key []byte) []byte {
 	return key[len(Prefix(bucket)):]
 }
+
+type Batch struct {
+	batch StoreBatch
+}
+
+func (b *Batch) Put(key []byte, value []byte) {
+	b.batch.Put(key, value)
+}
+
+func (b *Batch) Delete(key []byte) {
+	b.batch.Delete(key)
+}
+
+func (b *Batch) PutInBucket(bucket string, key, value []byte) {
+	b.batch.Put(Key(bucket, key), value)
+}
+
+func (b *Batch) DeleteFromBucket(bucket string, key []byte) {
+	b.batch.Delete(Key(bucket, key))
+}
+
+func (b *Batch) Len() int {
+	return b.batch.Len()
+}
+
+func (b *Batch) Reset() {
+	b.batch.Reset()
+}
@@ -0,0 +1,62 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+var ErrNotFound = leveldb.ErrNotFound
+
+type LevelDBStore struct {
+	db *leveldb.DB
+}
+
+func OpenLevelDB(path string) (*LevelDBStore, error) {
+	var err error
+	opts := &opt.Options{
+		OpenFilesCacheCapacity: 16,
+		Strict:                 opt.DefaultStrict,
+		Compression:            opt.NoCompression,
+		BlockCacheCapacity:     8 * opt.MiB,
+		WriteBuffer:            4 * opt.MiB,
+	}
+	s := &LevelDBStore{}
+	if s.db, err = leveldb.OpenFile(path, opts); err != nil {
+		if s.db, err = leveldb.RecoverFile(path, nil); err != nil {
+			return nil, errors.New(fmt.Sprintf(`err while recoverfile %s : %s`, path, err.Error()))
+		}
+
+	}
+	return s, nil
+}
+
+func (s *LevelDBStore) Get(key []byte) ([]byte, error) {
+	return s.db.Get(key, nil)
+}
+
+func (s *LevelDBStore) Put(key []byte, value []byte) error {
+	return s.db.Put(key, value, nil)
+}
+
+func (s *LevelDBStore) Delete(key []byte) error {
+	return s.db.Delete(key, nil)
+}
+
+func (s *LevelDBStore) NewIterator(prefix []byte) Iterator {
+	return s.db.NewIterator(util.BytesPrefix(prefix), nil)
+}
+
+func (s *LevelDBStore) NewBatch() StoreBatch {
+	return new(leveldb.Batch)
+}
+
+func (s *LevelDBStore) Write(batch StoreBatch) error {
+	return s.db.Write(batch.(*leveldb.Batch), nil)
+}
+
+func (s *LevelDBStore) Close() error {
+	return s.db.Close()
+}
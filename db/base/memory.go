@@ -0,0 +1,153 @@
+package base
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps everything in a map, for tests and short runs that
+// should not touch the disk.
+type MemoryStore struct {
+	mutex sync.RWMutex
+	data  map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+func (m *MemoryStore) Get(key []byte) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	value, ok := m.data[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyValue(value), nil
+}
+
+func (m *MemoryStore) Put(key []byte, value []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.data[string(key)] = copyValue(value)
+	return nil
+}
+
+func (m *MemoryStore) Delete(key []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.data, string(key))
+	return nil
+}
+
+func (m *MemoryStore) NewIterator(prefix []byte) Iterator {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	keys := make([]string, 0)
+	for key := range m.data {
+		if bytes.HasPrefix([]byte(key), prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = m.data[key]
+	}
+	return &memoryIterator{keys: keys, values: values, pos: -1}
+}
+
+func (m *MemoryStore) NewBatch() StoreBatch {
+	return &memoryBatch{}
+}
+
+func (m *MemoryStore) Write(batch StoreBatch) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, op := range batch.(*memoryBatch).ops {
+		if op.delete {
+			delete(m.data, op.key)
+		} else {
+			m.data[op.key] = op.value
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+type memoryIterator struct {
+	keys   []string
+	values [][]byte
+	pos    int
+}
+
+func (i *memoryIterator) Next() bool {
+	if i.pos < len(i.keys) {
+		i.pos++
+	}
+	return i.pos < len(i.keys)
+}
+
+func (i *memoryIterator) Key() []byte {
+	if i.pos < 0 || i.pos >= len(i.keys) {
+		return nil
+	}
+	return []byte(i.keys[i.pos])
+}
+
+func (i *memoryIterator) Value() []byte {
+	if i.pos < 0 || i.pos >= len(i.keys) {
+		return nil
+	}
+	return i.values[i.pos]
+}
+
+func (i *memoryIterator) Error() error {
+	return nil
+}
+
+func (i *memoryIterator) Release() {
+	i.keys = nil
+	i.values = nil
+}
+
+type memoryOp struct {
+	key    string
+	value  []byte
+	delete bool
+}
+
+type memoryBatch struct {
+	ops []memoryOp
+}
+
+func (b *memoryBatch) Put(key []byte, value []byte) {
+	b.ops = append(b.ops, memoryOp{key: string(key), value: copyValue(value)})
+}
+
+func (b *memoryBatch) Delete(key []byte) {
+	b.ops = append(b.ops, memoryOp{key: string(key), delete: true})
+}
+
+func (b *memoryBatch) Len() int {
+	return len(b.ops)
+}
+
+func (b *memoryBatch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+func copyValue(value []byte) []byte {
+	cp := make([]byte, len(value))
+	copy(cp, value)
+	return cp
+}
@@ -0,0 +1,84 @@
+package base
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/encode"
+	"sort"
+)
+
+const (
+	schema_bucket = "schema_bucket"
+	version_key   = "version"
+)
+
+// Migration upgrades a database from Version-1 to Version.
+type Migration struct {
+	Version     uint64
+	Description string
+	Migrate     func(b *Base) error
+}
+
+// SchemaVersion returns the version recorded in the database, ok is false
+// for databases written before versioning.
+func (b *Base) SchemaVersion() (uint64, bool, error) {
+	bytes, err := b.GetFromBucket(schema_bucket, []byte(version_key))
+	if err == ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return encode.BytesToUint64(bytes), true, nil
+}
+
+func (b *Base) SetSchemaVersion(version uint64) error {
+	return b.PutInBucket(schema_bucket, []byte(version_key), encode.Uint64ToBytes(version))
+}
+
+func (b *Base) empty() bool {
+	iter := b.db.NewIterator(nil)
+	defer iter.Release()
+	return !iter.Next()
+}
+
+// Upgrade runs the migrations newer than the version of the database, in
+// order, recording the version after each of them. A new database is
+// stamped with the latest version, unversioned ones are treated as
+// version initial and a database newer than the latest version is refused.
+func (b *Base) Upgrade(initial uint64, migrations []Migration) error {
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	latest := initial
+	if len(migrations) != 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+
+	version, ok, err := b.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if b.empty() {
+			return b.SetSchemaVersion(latest)
+		}
+		version = initial
+	}
+	if version > latest {
+		return fmt.Errorf("schema version %d is newer than the supported version %d", version, latest)
+	}
+	if !ok && version == latest {
+		// Nothing to migrate, record the version it is at
+		return b.SetSchemaVersion(latest)
+	}
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := m.Migrate(b); err != nil {
+			return fmt.Errorf("migrate to version %d (%s) failed, %s", m.Version, m.Description, err.Error())
+		}
+		if err := b.SetSchemaVersion(m.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
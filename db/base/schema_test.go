@@ -0,0 +1,129 @@
+package base
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordMigrations are migrations 2 to 4 that record the versions they ran
+// in ran, fail is the version that fails.
+func recordMigrations(ran *[]uint64, fail uint64) []Migration {
+	migrations := make([]Migration, 0)
+	for _, v := range []uint64{4, 2, 3} {
+		version := v
+		migrations = append(migrations, Migration{Version: version, Description: "test", Migrate: func(b *Base) error {
+			if version == fail {
+				return errors.New("broken")
+			}
+			*ran = append(*ran, version)
+			return nil
+		}})
+	}
+	return migrations
+}
+
+func schemaVersion(t *testing.T, b *Base) uint64 {
+	version, ok, err := b.SchemaVersion()
+	if err != nil || !ok {
+		t.Fatalf("no schema version, %v", err)
+	}
+	return version
+}
+
+func TestUpgradeNew(t *testing.T) {
+	b := New(NewMemoryStore())
+	var ran []uint64
+	if err := b.Upgrade(1, recordMigrations(&ran, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 0 {
+		t.Fatalf("migrated a new database %v", ran)
+	}
+	if v := schemaVersion(t, b); v != 4 {
+		t.Fatalf("version %d", v)
+	}
+}
+
+func TestUpgradeForward(t *testing.T) {
+	b := New(NewMemoryStore())
+	b.PutInBucket("data", []byte("k"), []byte("v"))
+	var ran []uint64
+	if err := b.Upgrade(1, recordMigrations(&ran, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ran, []uint64{2, 3, 4}) {
+		t.Fatalf("ran %v", ran)
+	}
+	if v := schemaVersion(t, b); v != 4 {
+		t.Fatalf("version %d", v)
+	}
+}
+
+func TestUpgradeFromVersion(t *testing.T) {
+	b := New(NewMemoryStore())
+	b.SetSchemaVersion(2)
+	var ran []uint64
+	if err := b.Upgrade(1, recordMigrations(&ran, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ran, []uint64{3, 4}) {
+		t.Fatalf("ran %v", ran)
+	}
+}
+
+func TestUpgradeCurrent(t *testing.T) {
+	b := New(NewMemoryStore())
+	b.SetSchemaVersion(4)
+	var ran []uint64
+	if err := b.Upgrade(1, recordMigrations(&ran, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 0 {
+		t.Fatalf("ran %v on a current database", ran)
+	}
+}
+
+func TestUpgradeFailure(t *testing.T) {
+	b := New(NewMemoryStore())
+	b.PutInBucket("data", []byte("k"), []byte("v"))
+	var ran []uint64
+	if err := b.Upgrade(1, recordMigrations(&ran, 3)); err == nil {
+		t.Fatal("a failed migration was not reported")
+	}
+	if v := schemaVersion(t, b); v != 2 {
+		t.Fatalf("version %d after migration 3 failed", v)
+	}
+	// The next open goes on from the failed migration
+	ran = nil
+	if err := b.Upgrade(1, recordMigrations(&ran, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ran, []uint64{3, 4}) {
+		t.Fatalf("ran %v", ran)
+	}
+}
+
+func TestUpgradeNewer(t *testing.T) {
+	b := New(NewMemoryStore())
+	b.SetSchemaVersion(5)
+	var ran []uint64
+	if err := b.Upgrade(1, recordMigrations(&ran, 0)); err == nil {
+		t.Fatal("a newer database was accepted")
+	}
+}
+
+func TestUpgradeWithoutMigrations(t *testing.T) {
+	b := New(NewMemoryStore())
+	b.PutInBucket("data", []byte("k"), []byte("v"))
+	if err := b.Upgrade(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if v := schemaVersion(t, b); v != 1 {
+		t.Fatalf("version %d", v)
+	}
+	b.SetSchemaVersion(2)
+	if err := b.Upgrade(1, nil); err == nil {
+		t.Fatal("a newer database was accepted")
+	}
+}
@@ -0,0 +1,47 @@
+package base
+
+import "fmt"
+
+const (
+	BackendLevelDB = "leveldb"
+	BackendMemory  = "memory"
+)
+
+// Store is the key value store under Base. Iterators walk the keys with a
+// prefix in ascending order over a snapshot taken when they are created.
+type Store interface {
+	Get(key []byte) ([]byte, error)
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	NewIterator(prefix []byte) Iterator
+	NewBatch() StoreBatch
+	Write(batch StoreBatch) error
+	Close() error
+}
+
+type Iterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Release()
+}
+
+// StoreBatch collects writes that Store.Write applies atomically.
+type StoreBatch interface {
+	Put(key []byte, value []byte)
+	Delete(key []byte)
+	Len() int
+	Reset()
+}
+
+// OpenBackend opens the store named by backend, an empty backend is leveldb.
+func OpenBackend(backend, path string) (*Base, error) {
+	switch backend {
+	case BackendLevelDB, "":
+		return Open(path)
+	case BackendMemory:
+		return New(NewMemoryStore()), nil
+	}
+	return nil, fmt.Errorf("unknown db backend %s", backend)
+}
@@ -0,0 +1,207 @@
+package base
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// forEachBackend runs the test on a new store of every backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, s Store)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) (Store, func())
+	}{
+		{BackendMemory, func(t *testing.T) (Store, func()) {
+			return NewMemoryStore(), func() {}
+		}},
+		{BackendLevelDB, func(t *testing.T) (Store, func()) {
+			dir, err := ioutil.TempDir("", "store")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := OpenLevelDB(filepath.Join(dir, "db"))
+			if err != nil {
+				os.RemoveAll(dir)
+				t.Fatal(err)
+			}
+			return s, func() {
+				s.Close()
+				os.RemoveAll(dir)
+			}
+		}},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			s, closeStore := backend.open(t)
+			defer closeStore()
+			test(t, s)
+		})
+	}
+}
+
+func keys(t *testing.T, it Iterator) []string {
+	defer it.Release()
+	found := make([]string, 0)
+	for it.Next() {
+		found = append(found, string(it.Key())+"="+string(it.Value()))
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestStoreGetPutDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		tests := []struct {
+			name  string
+			op    func() error
+			key   string
+			value string
+			found bool
+		}{
+			{name: "missing", key: "a"},
+			{name: "put", op: func() error { return s.Put([]byte("a"), []byte("1")) }, key: "a", value: "1", found: true},
+			{name: "overwrite", op: func() error { return s.Put([]byte("a"), []byte("2")) }, key: "a", value: "2", found: true},
+			{name: "empty value", op: func() error { return s.Put([]byte("b"), []byte{}) }, key: "b", value: "", found: true},
+			{name: "delete", op: func() error { return s.Delete([]byte("a")) }, key: "a"},
+			{name: "delete missing", op: func() error { return s.Delete([]byte("x")) }, key: "x"},
+		}
+		for _, test := range tests {
+			if test.op != nil {
+				if err := test.op(); err != nil {
+					t.Fatalf("%s: %v", test.name, err)
+				}
+			}
+			value, err := s.Get([]byte(test.key))
+			if !test.found {
+				if err != ErrNotFound {
+					t.Fatalf("%s: got %q, %v, want not found", test.name, value, err)
+				}
+				continue
+			}
+			if err != nil || string(value) != test.value {
+				t.Fatalf("%s: got %q, %v, want %q", test.name, value, err, test.value)
+			}
+		}
+	})
+}
+
+func TestStoreCopiesValues(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		value := []byte("abc")
+		s.Put([]byte("k"), value)
+		value[0] = 'x'
+		got, _ := s.Get([]byte("k"))
+		got[1] = 'y'
+		if again, _ := s.Get([]byte("k")); string(again) != "abc" {
+			t.Fatalf("stored value changed to %q", again)
+		}
+	})
+}
+
+func TestStoreIterator(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		for _, k := range []string{"b-2", "a-1", "b-1", "c-1", "b-10", "b"} {
+			s.Put([]byte(k), []byte(k))
+		}
+		tests := []struct {
+			prefix string
+			want   []string
+		}{
+			{prefix: "b-", want: []string{"b-1=b-1", "b-10=b-10", "b-2=b-2"}},
+			{prefix: "", want: []string{"a-1=a-1", "b=b", "b-1=b-1", "b-10=b-10", "b-2=b-2", "c-1=c-1"}},
+			{prefix: "d", want: []string{}},
+		}
+		for _, test := range tests {
+			if got := keys(t, s.NewIterator([]byte(test.prefix))); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("prefix %q: got %v, want %v", test.prefix, got, test.want)
+			}
+		}
+
+		// The iterator sees the store as it was when it was created
+		it := s.NewIterator([]byte("b-"))
+		s.Put([]byte("b-3"), []byte("new"))
+		s.Delete([]byte("b-1"))
+		s.Put([]byte("b-2"), []byte("changed"))
+		if got := keys(t, it); !reflect.DeepEqual(got, []string{"b-1=b-1", "b-10=b-10", "b-2=b-2"}) {
+			t.Errorf("snapshot: got %v", got)
+		}
+	})
+}
+
+func TestStoreBatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		s.Put([]byte("old"), []byte("1"))
+		batch := s.NewBatch()
+		batch.Put([]byte("a"), []byte("1"))
+		batch.Put([]byte("a"), []byte("2"))
+		batch.Put([]byte("b"), []byte("1"))
+		batch.Delete([]byte("b"))
+		batch.Delete([]byte("old"))
+		if batch.Len() != 5 {
+			t.Fatalf("len %d", batch.Len())
+		}
+		if _, err := s.Get([]byte("a")); err != ErrNotFound {
+			t.Fatal("batch applied before Write")
+		}
+		if err := s.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+		if got := keys(t, s.NewIterator(nil)); !reflect.DeepEqual(got, []string{"a=2"}) {
+			t.Fatalf("after write %v", got)
+		}
+
+		batch.Reset()
+		if batch.Len() != 0 {
+			t.Fatalf("len %d after reset", batch.Len())
+		}
+		batch.Put([]byte("c"), []byte("3"))
+		if err := s.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+		if got := keys(t, s.NewIterator(nil)); !reflect.DeepEqual(got, []string{"a=2", "c=3"}) {
+			t.Fatalf("after reset and write %v", got)
+		}
+	})
+}
+
+func TestBaseBuckets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		b := New(s)
+		b.PutInBucket("tx", []byte("1"), []byte("a"))
+		b.PutInBucket("tx", []byte("2"), []byte("b"))
+		b.PutInBucket("txs", []byte("1"), []byte("c"))
+		batch := b.NewBatch()
+		batch.PutInBucket("tx", []byte("3"), []byte("d"))
+		batch.DeleteFromBucket("tx", []byte("1"))
+		if err := b.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+
+		if value, err := b.GetFromBucket("tx", []byte("3")); err != nil || !bytes.Equal(value, []byte("d")) {
+			t.Fatalf("got %q, %v", value, err)
+		}
+		want := map[string][]byte{"tx-2": []byte("b"), "tx-3": []byte("d")}
+		if got := b.Foreach("tx"); !reflect.DeepEqual(got, want) {
+			t.Fatalf("foreach %v", got)
+		}
+		it := b.Iter("tx")
+		leaves := make([]string, 0)
+		for it.Next() {
+			leaves = append(leaves, string(LeafKeyToKey("tx", it.Key())))
+		}
+		it.Release()
+		if !reflect.DeepEqual(leaves, []string{"2", "3"}) {
+			t.Fatalf("leaves %v", leaves)
+		}
+		b.Clear("tx")
+		if len(b.Foreach("tx")) != 0 || len(b.Foreach("txs")) != 1 {
+			t.Fatal("clear touched the wrong bucket")
+		}
+	})
+}
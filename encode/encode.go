@@ -1,6 +1,12 @@
 package encode
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+var ErrShortBuffer = errors.New("unexpected end of buffer")
 
 func Uint64ToBytes(val uint64) []byte {
 	var buf = make([]byte, 8)
@@ -11,3 +17,116 @@ func Uint64ToBytes(val uint64) []byte {
 func BytesToUint64(val []byte) uint64 {
 	return binary.BigEndian.Uint64(val)
 }
+
+// Writer appends values in a compact binary form, integers are varints and
+// byte slices are length prefixed.
+type Writer struct {
+	buf []byte
+}
+
+func NewWriter(size int) *Writer {
+	return &Writer{buf: make([]byte, 0, size)}
+}
+
+func (w *Writer) Byte(b byte) {
+	w.buf = append(w.buf, b)
+}
+
+func (w *Writer) Uint64(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	w.buf = append(w.buf, tmp[:n]...)
+}
+
+func (w *Writer) Bytes(b []byte) {
+	w.Uint64(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *Writer) String(s string) {
+	w.Uint64(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// Hex stores a hex string as its raw bytes, strings that are not valid
+// lower case hex are stored as they are.
+func (w *Writer) Hex(s string) {
+	raw, err := hex.DecodeString(s)
+	if err != nil || hex.EncodeToString(raw) != s {
+		w.Byte(0)
+		w.String(s)
+		return
+	}
+	w.Byte(1)
+	w.Bytes(raw)
+}
+
+func (w *Writer) Result() []byte {
+	return w.buf
+}
+
+// Reader reads the values written by Writer, the first error is kept and
+// returned by Error.
+type Reader struct {
+	buf []byte
+	err error
+}
+
+func NewReader(buf []byte) *Reader {
+	return &Reader{buf: buf}
+}
+
+func (r *Reader) Byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 1 {
+		r.err = ErrShortBuffer
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *Reader) Uint64() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = ErrShortBuffer
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *Reader) Bytes() []byte {
+	n := r.Uint64()
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.buf)) < n {
+		r.err = ErrShortBuffer
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *Reader) String() string {
+	return string(r.Bytes())
+}
+
+func (r *Reader) Hex() string {
+	if r.Byte() == 1 {
+		return hex.EncodeToString(r.Bytes())
+	}
+	return r.String()
+}
+
+func (r *Reader) Error() error {
+	return r.err
+}
@@ -6,5 +6,7 @@ require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/bCoder778/log v0.0.0-20200815025303-b2d7a30e10e7
 	github.com/btcsuite/goleveldb v1.0.0
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1
 	github.com/go-gomail/gomail v0.0.0-20160411212932-81ebce5c23df // indirect
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 )
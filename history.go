@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/history"
+	"time"
+)
+
+var (
+	listRuns = flag.Bool("runs", false, "list the recorded runs and exit")
+	showRun  = flag.Uint64("run", 0, "print the findings of the recorded run with when each was first seen and exit")
+	listOpen = flag.Bool("issues", false, "list the findings that are not resolved and exit")
+	ackID    = flag.String("ack", "", "acknowledge the finding with this id as a known issue and exit")
+	ackNote  = flag.String("note", "", "note kept with an acknowledged finding")
+)
+
+// historyCommand runs the history query given on the command line, ok is
+// false when there is none.
+func historyCommand() (ok bool, err error) {
+	if !*listRuns && *showRun == 0 && !*listOpen && *ackID == "" {
+		return false, nil
+	}
+	h, err := history.Open(conf.Setting.History.Path)
+	if err != nil {
+		return true, err
+	}
+	defer h.Close()
+
+	if *ackID != "" {
+		if err := h.Ack(*ackID, *ackNote); err != nil {
+			return true, err
+		}
+		fmt.Printf("Acknowledged %s\n", *ackID)
+		return true, nil
+	}
+	if *listOpen {
+		issues, err := h.Issues()
+		if err != nil {
+			return true, err
+		}
+		for _, issue := range issues {
+			if issue.Status == history.StatusResolved {
+				continue
+			}
+			ack := ""
+			if issue.Acked {
+				ack = fmt.Sprintf(" acked=%q", issue.AckNote)
+			}
+			fmt.Printf("[%s] %s order=%d kind=%s runs=%d-%d%s %s\n", issue.ID, issue.Validator, issue.Order,
+				issue.Kind, issue.FirstRun, issue.LastRun, ack, issue.Message)
+		}
+		return true, nil
+	}
+	if *listRuns {
+		runs, err := h.Runs()
+		if err != nil {
+			return true, err
+		}
+		for _, run := range runs {
+			fmt.Printf("run=%d start=%s use=%ds release=%s test=%s orders=%d-%d blocks=%d release-utxo=%d test-utxo=%d findings=%d\n",
+				run.ID, time.Unix(run.Start, 0).Format("2006-01-02 15:04:05"), run.End-run.Start,
+				run.ReleaseVersion, run.TestVersion, run.FirstOrder, run.LastOrder,
+				run.ReleaseCount, run.ReleaseUtxo, run.TestUtxo, len(run.Findings))
+		}
+		return true, nil
+	}
+
+	run, err := h.Get(*showRun)
+	if err != nil {
+		return true, fmt.Errorf("get run %d failed, %s", *showRun, err.Error())
+	}
+	for _, f := range run.Findings {
+		first, err := h.FirstSeen(&f)
+		if err != nil {
+			return true, err
+		}
+		fmt.Printf("%s order=%d first-run=%d %s\n", f.Validator, f.Order, first.ID, f.Message)
+	}
+	return true, nil
+}
@@ -0,0 +1,187 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+	"github.com/bCoder778/qitmeer_test/encode"
+	"time"
+)
+
+const (
+	run_bucket  = "run_bucket"
+	last_run    = "last_run"
+	meta_bucket = "meta_bucket"
+
+	schema_version = 1
+
+	open_wait  = 5 * time.Second
+	open_retry = 100 * time.Millisecond
+)
+
+// Finding is one failed verification of a run, ID is the same for the same
+// failure in every run.
+type Finding struct {
+	ID        string
+	Validator string
+	Order     uint64
+	Hash      string
+	Kind      string
+	Message   string
+	Fields    []Field `json:",omitempty"`
+}
+
+// Field is a value behind a finding as the release and test nodes have it,
+// empty for a node it was not found on.
+type Field struct {
+	Name    string
+	Release string
+	Test    string
+}
+
+// Run is the record of one comparison of the release and test nodes.
+type Run struct {
+	ID             uint64
+	Start          int64
+	End            int64
+	ReleaseVersion string
+	TestVersion    string
+	FirstOrder     uint64
+	LastOrder      uint64
+	ReleaseCount   uint64
+	TestCount      uint64
+	ReleaseUtxo    uint64
+	TestUtxo       uint64
+	Findings       []Finding
+	Canceled       bool `json:",omitempty"`
+}
+
+// Verified tells if the run verified the block of the finding again.
+// Findings without a block, like the account check, are verified by every
+// run that verified any block.
+func (r *Run) Verified(f *Finding) bool {
+	if r.ReleaseCount == 0 {
+		return false
+	}
+	if f.Hash == "" {
+		return true
+	}
+	return f.Order >= r.FirstOrder && f.Order <= r.LastOrder
+}
+
+// History keeps every run, it is never wiped.
+type History struct {
+	base *base.Base
+}
+
+// Open opens the history at path. The tester and the command line only
+// hold it briefly, when another process has it open Open waits up to
+// open_wait for it.
+func Open(path string) (*History, error) {
+	deadline := time.Now().Add(open_wait)
+	b, err := base.Open(path)
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(open_retry)
+		b, err = base.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Upgrade(schema_version, nil); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("open %s failed, %s", path, err.Error())
+	}
+	return &History{base: b}, nil
+}
+
+func (h *History) Close() {
+	h.base.Close()
+}
+
+// Add stores the run with the next id and updates the issues with its
+// findings, it returns how the issues changed.
+func (h *History) Add(run *Run) (*Changes, error) {
+	run.ID = h.LastID() + 1
+	bytes, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	batch := h.base.NewBatch()
+	changes, err := h.track(batch, run)
+	if err != nil {
+		return nil, err
+	}
+	batch.PutInBucket(run_bucket, encode.Uint64ToBytes(run.ID), bytes)
+	batch.PutInBucket(meta_bucket, []byte(last_run), encode.Uint64ToBytes(run.ID))
+	if err := h.base.Write(batch); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// LastID is the id of the latest run, 0 when there is none.
+func (h *History) LastID() uint64 {
+	bytes, err := h.base.GetFromBucket(meta_bucket, []byte(last_run))
+	if err != nil {
+		return 0
+	}
+	return encode.BytesToUint64(bytes)
+}
+
+func (h *History) Get(id uint64) (*Run, error) {
+	bytes, err := h.base.GetFromBucket(run_bucket, encode.Uint64ToBytes(id))
+	if err != nil {
+		return nil, err
+	}
+	return bytesToRun(bytes)
+}
+
+// Runs returns every run, oldest first.
+func (h *History) Runs() ([]*Run, error) {
+	runs := make([]*Run, 0)
+	err := h.each(func(run *Run) bool {
+		runs = append(runs, run)
+		return true
+	})
+	return runs, err
+}
+
+// FirstSeen returns the oldest run that found the same validator failing
+// on the same block, nil if no run did.
+func (h *History) FirstSeen(f *Finding) (*Run, error) {
+	var first *Run
+	err := h.each(func(run *Run) bool {
+		for _, found := range run.Findings {
+			if found.Validator == f.Validator && found.Order == f.Order && found.Hash == f.Hash {
+				first = run
+				return false
+			}
+		}
+		return true
+	})
+	return first, err
+}
+
+func (h *History) each(fn func(run *Run) bool) error {
+	iter := h.base.Iter(run_bucket)
+	defer iter.Release()
+
+	for iter.Next() {
+		run, err := bytesToRun(iter.Value())
+		if err != nil {
+			return err
+		}
+		if !fn(run) {
+			return nil
+		}
+	}
+	return iter.Error()
+}
+
+func bytesToRun(bytes []byte) (*Run, error) {
+	var run *Run
+	if err := json.Unmarshal(bytes, &run); err != nil {
+		return nil, fmt.Errorf("decode run failed, %s", err.Error())
+	}
+	return run, nil
+}
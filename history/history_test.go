@@ -0,0 +1,113 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The tester and the command line open the history in turns, Open waits
+// for the other to close it.
+func TestOpenWaits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history_db")
+
+	h, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		h.Add(&Run{Start: 1})
+		h.Close()
+	}()
+	other, err := Open(path)
+	if err != nil {
+		t.Fatalf("open while held: %v", err)
+	}
+	defer other.Close()
+	if other.LastID() != 1 {
+		t.Fatalf("last id %d, the run of the first holder is missing", other.LastID())
+	}
+}
+
+func TestAddAndAck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h, err := Open(filepath.Join(dir, "history_db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	f := Finding{ID: FindingID("fees", 5, "h", "fee"), Validator: "fees", Order: 5, Hash: "h", Kind: "fee"}
+	changes, err := h.Add(&Run{FirstOrder: 0, LastOrder: 10, Findings: []Finding{f}})
+	if err != nil || len(changes.New) != 1 {
+		t.Fatalf("first run %+v, %v", changes, err)
+	}
+	if err := h.Ack(f.ID, "known"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Ack("missing", ""); err == nil {
+		t.Fatal("acked a missing issue")
+	}
+	changes, err = h.Add(&Run{FirstOrder: 0, LastOrder: 10, Findings: []Finding{f}})
+	if err != nil || len(changes.Recurring) != 1 || !changes.Recurring[0].Acked || changes.Recurring[0].AckNote != "known" {
+		t.Fatalf("second run %+v, %v", changes, err)
+	}
+	if known, err := h.Known(); err != nil || !known[f.ID] {
+		t.Fatalf("known %v, %v", known, err)
+	}
+	changes, err = h.Add(&Run{FirstOrder: 0, LastOrder: 10, ReleaseCount: 11})
+	if err != nil || len(changes.Resolved) != 1 {
+		t.Fatalf("third run %+v, %v", changes, err)
+	}
+}
+
+// A run resumed on kept databases replays the orders of the earlier run
+// without verifying them, the issues at those orders stay open.
+func TestResolveOnlyVerified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h, err := Open(filepath.Join(dir, "history_db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	block := Finding{ID: FindingID("fees", 5, "h", "fee"), Validator: "fees", Order: 5, Hash: "h", Kind: "fee"}
+	account := Finding{ID: FindingID("account", 0, "", "test-supply"), Validator: "account", Kind: "test-supply"}
+	if _, err := h.Add(&Run{FirstOrder: 0, LastOrder: 10, ReleaseCount: 11, Findings: []Finding{block, account}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		run      *Run
+		resolved int
+	}{
+		{name: "nothing verified", run: &Run{FirstOrder: 0, LastOrder: 10}},
+		{name: "later orders", run: &Run{FirstOrder: 11, LastOrder: 20, ReleaseCount: 10}, resolved: 1},
+		{name: "verified again", run: &Run{FirstOrder: 3, LastOrder: 30, ReleaseCount: 28}, resolved: 1},
+	}
+	for _, test := range tests {
+		changes, err := h.Add(test.run)
+		if err != nil || len(changes.Resolved) != test.resolved {
+			t.Fatalf("%s: %+v, %v", test.name, changes, err)
+		}
+	}
+	if issue, err := h.Issue(block.ID); err != nil || issue.Status != StatusResolved {
+		t.Fatalf("block issue %+v, %v", issue, err)
+	}
+}
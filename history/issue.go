@@ -0,0 +1,169 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/db/base"
+)
+
+const issue_bucket = "issue_bucket"
+
+type Status string
+
+const (
+	StatusNew       Status = "new"
+	StatusRecurring Status = "recurring"
+	StatusResolved  Status = "resolved"
+)
+
+// Issue follows a finding across runs. Finding is its latest occurrence.
+type Issue struct {
+	Finding
+	FirstRun uint64
+	LastRun  uint64
+	Status   Status
+	Acked    bool
+	AckNote  string
+}
+
+// Changes is how a run changed the issues. A resolved issue found again is
+// new, unless it was acknowledged.
+type Changes struct {
+	New       []*Issue
+	Recurring []*Issue
+	Resolved  []*Issue
+}
+
+// FindingID identifies the failure of validator on a block, kind tells
+// apart the different failures of one validator.
+func FindingID(validator string, order uint64, hash, kind string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%s", validator, order, hash, kind)))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// track adds the issue updates of the run to the batch. Open issues the
+// run did not find again are resolved only if the run verified their order
+// again.
+func (h *History) track(batch *base.Batch, run *Run) (*Changes, error) {
+	issues, err := h.Issues()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]*Issue, len(issues))
+	for _, issue := range issues {
+		known[issue.ID] = issue
+	}
+
+	changes := &Changes{New: make([]*Issue, 0), Recurring: make([]*Issue, 0), Resolved: make([]*Issue, 0)}
+	found := make(map[string]bool)
+	for _, f := range run.Findings {
+		if found[f.ID] {
+			continue
+		}
+		found[f.ID] = true
+		issue, ok := known[f.ID]
+		switch {
+		case !ok:
+			issue = &Issue{FirstRun: run.ID, Status: StatusNew}
+			changes.New = append(changes.New, issue)
+		case issue.Status == StatusResolved && !issue.Acked:
+			issue.Status = StatusNew
+			changes.New = append(changes.New, issue)
+		default:
+			issue.Status = StatusRecurring
+			changes.Recurring = append(changes.Recurring, issue)
+		}
+		issue.Finding = f
+		issue.LastRun = run.ID
+		if err := putIssue(batch, issue); err != nil {
+			return nil, err
+		}
+	}
+	for _, issue := range issues {
+		if found[issue.ID] || issue.Status == StatusResolved {
+			continue
+		}
+		if !run.Verified(&issue.Finding) {
+			continue
+		}
+		issue.Status = StatusResolved
+		changes.Resolved = append(changes.Resolved, issue)
+		if err := putIssue(batch, issue); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+func putIssue(batch *base.Batch, issue *Issue) error {
+	bytes, err := json.Marshal(issue)
+	if err != nil {
+		return err
+	}
+	batch.PutInBucket(issue_bucket, []byte(issue.ID), bytes)
+	return nil
+}
+
+func (h *History) Issue(id string) (*Issue, error) {
+	bytes, err := h.base.GetFromBucket(issue_bucket, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+	return bytesToIssue(bytes)
+}
+
+func (h *History) Issues() ([]*Issue, error) {
+	issues := make([]*Issue, 0)
+	iter := h.base.Iter(issue_bucket)
+	defer iter.Release()
+
+	for iter.Next() {
+		issue, err := bytesToIssue(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, iter.Error()
+}
+
+// Known returns the ids of the open and the acknowledged issues, which do
+// not need to be reported again when found.
+func (h *History) Known() (map[string]bool, error) {
+	issues, err := h.Issues()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, issue := range issues {
+		if issue.Status != StatusResolved || issue.Acked {
+			known[issue.ID] = true
+		}
+	}
+	return known, nil
+}
+
+// Ack acknowledges a known issue, it is no longer highlighted when it
+// recurs.
+func (h *History) Ack(id, note string) error {
+	issue, err := h.Issue(id)
+	if err != nil {
+		return fmt.Errorf("get issue %s failed, %s", id, err.Error())
+	}
+	issue.Acked = true
+	issue.AckNote = note
+	batch := h.base.NewBatch()
+	if err := putIssue(batch, issue); err != nil {
+		return err
+	}
+	return h.base.Write(batch)
+}
+
+func bytesToIssue(bytes []byte) (*Issue, error) {
+	var issue *Issue
+	if err := json.Unmarshal(bytes, &issue); err != nil {
+		return nil, fmt.Errorf("decode issue failed, %s", err.Error())
+	}
+	return issue, nil
+}
@@ -1,16 +1,35 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"github.com/bCoder778/log"
+	"github.com/bCoder778/qitmeer_test/api"
+	"github.com/bCoder778/qitmeer_test/check"
 	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/dashboard"
+	"github.com/bCoder778/qitmeer_test/metrics"
+	"github.com/bCoder778/qitmeer_test/notify"
 	"github.com/bCoder778/qitmeer_test/test"
 	"github.com/bCoder778/qitmeer_test/timer"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 )
 
 func main() {
+	flag.Parse()
+	for _, command := range []func() (bool, error){snapshotCommand, historyCommand} {
+		if ok, err := command(); ok {
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	log.SetOption(&log.Option{
 		LogLevel: conf.Setting.Log.Level,
 		Mode:     conf.Setting.Log.Mode,
@@ -23,17 +42,58 @@ func main() {
 		},
 	})
 
-	t := timer.New()
-	t.Start(test.TestQitmeer, conf.Setting.Timestamp, conf.Setting.Interval)
+	if _, err := check.PruneSetting(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	notifier, err := notify.New(&conf.Setting.Notify, &conf.Setting.Email)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	notify.SetDefault(notifier)
+
+	if conf.Setting.HTTP.Listen != "" {
+		go serve(conf.Setting.HTTP.Listen)
+	}
 
-	c := make(chan os.Signal)
+	schedule, err := timer.NewSchedule(&conf.Setting.Task)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	t, err := timer.New(schedule, conf.Setting.Missed)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	t.Start(test.TestQitmeer)
+
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, os.Kill)
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
 		_ = <-c
+		// Cancel the run so waiting for it is quick, the notifier must
+		// outlive it to send its report
+		test.Cancel()
 		t.Stop()
+		test.Wait()
+		notifier.Close()
 		wg.Done()
 	}()
 	wg.Wait()
 }
+
+func serve(listen string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	api.Register(mux)
+	dashboard.Register(mux)
+	log.Infof("Serve http on %s", listen)
+	if err := http.ListenAndServe(listen, mux); err != nil {
+		log.Errorf("Serve http on %s failed, err=%s", listen, err.Error())
+	}
+}
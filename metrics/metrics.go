@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type kind string
+
+const (
+	counter   kind = "counter"
+	gauge     kind = "gauge"
+	histogram kind = "histogram"
+)
+
+// Family is a metric with its series, one per combination of label values,
+// written in the Prometheus text format.
+type Family struct {
+	mutex   sync.Mutex
+	kind    kind
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+var registry = struct {
+	sync.Mutex
+	families []*Family
+}{}
+
+func register(f *Family) *Family {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.families = append(registry.families, f)
+	return f
+}
+
+func NewCounter(name, help string, labels ...string) *Family {
+	return register(&Family{kind: counter, name: name, help: help, labels: labels, series: make(map[string]*series)})
+}
+
+func NewGauge(name, help string, labels ...string) *Family {
+	return register(&Family{kind: gauge, name: name, help: help, labels: labels, series: make(map[string]*series)})
+}
+
+// NewHistogram counts observations into the upper bounds of buckets, which
+// must be ascending.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Family {
+	return register(&Family{kind: histogram, name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*series)})
+}
+
+func (f *Family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values}
+		if f.kind == histogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Add adds v to a counter or gauge.
+func (f *Family) Add(v float64, values ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.get(values).value += v
+}
+
+func (f *Family) Inc(values ...string) {
+	f.Add(1, values...)
+}
+
+// Set sets a gauge.
+func (f *Family) Set(v float64, values ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.get(values).value = v
+}
+
+// Observe adds an observation to a histogram.
+func (f *Family) Observe(v float64, values ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	s := f.get(values)
+	for i, bound := range f.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// Value returns the value of a counter or gauge, 0 for an unknown series.
+func (f *Family) Value(values ...string) float64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if s, ok := f.series[strings.Join(values, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (f *Family) write(w io.Writer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != histogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelString(s.values, "", ""), formatFloat(s.value))
+			continue
+		}
+		for i, bound := range f.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelString(s.values, "le", formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelString(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelString(s.values, "", ""), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelString(s.values, "", ""), s.count)
+	}
+}
+
+func (f *Family) labelString(values []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", f.labels[i], escapeLabel(v)))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// Write writes every registered metric in the Prometheus text format.
+func Write(w io.Writer) {
+	registry.Lock()
+	families := append([]*Family{}, registry.families...)
+	registry.Unlock()
+	for _, f := range families {
+		f.write(w)
+	}
+}
+
+// Handler serves the registered metrics.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func written(f *Family) string {
+	var buf bytes.Buffer
+	f.write(&buf)
+	return buf.String()
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	c := NewCounter("test_events_total", "Events seen.", "node")
+	c.Inc("release")
+	c.Add(2.5, "release")
+	c.Inc("test")
+	want := `# HELP test_events_total Events seen.
+# TYPE test_events_total counter
+test_events_total{node="release"} 3.5
+test_events_total{node="test"} 1
+`
+	if got := written(c); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	if c.Value("release") != 3.5 || c.Value("missing") != 0 {
+		t.Fatalf("values %v %v", c.Value("release"), c.Value("missing"))
+	}
+
+	g := NewGauge("test_order", "Last order.")
+	g.Set(10)
+	g.Set(math.Inf(1))
+	if got := written(g); !strings.HasSuffix(got, "test_order +Inf\n") {
+		t.Fatalf("gauge %q", got)
+	}
+}
+
+// Every bucket counts the observations up to its bound, so the counts grow
+// with the bounds and +Inf is the total.
+func TestHistogramBuckets(t *testing.T) {
+	h := NewHistogram("test_seconds", "Durations.", []float64{0.1, 1, 10}, "method")
+	for _, v := range []float64{0.05, 0.1, 0.5, 5, 50} {
+		h.Observe(v, "get")
+	}
+	want := `# HELP test_seconds Durations.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="get",le="0.1"} 2
+test_seconds_bucket{method="get",le="1"} 3
+test_seconds_bucket{method="get",le="10"} 4
+test_seconds_bucket{method="get",le="+Inf"} 5
+test_seconds_sum{method="get"} 55.65
+test_seconds_count{method="get"} 5
+`
+	if got := written(h); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	c := NewCounter("test_escaped_total", "Help with \\ and\nnewline.", "kind")
+	c.Inc("a\"b\\c\nd")
+	want := `# HELP test_escaped_total Help with \\ and\nnewline.
+# TYPE test_escaped_total counter
+test_escaped_total{kind="a\"b\\c\nd"} 1
+`
+	if got := written(c); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("wrong number of label values accepted")
+		}
+	}()
+	NewCounter("test_labels_total", "Labels.", "a", "b").Inc("a")
+}
+
+func TestHandler(t *testing.T) {
+	NewCounter("test_served_total", "Served.").Inc()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("content type %q", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "\ntest_served_total 1\n") || !strings.Contains(w.Body.String(), "# TYPE qitmeer_runs_total counter") {
+		t.Fatalf("body misses metrics\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+// The metrics of the tester, node is release or test.
+var (
+	FetchedOrder   = NewGauge("qitmeer_fetched_order", "Latest order fetched from the node.", "node")
+	VerifiedOrder  = NewGauge("qitmeer_verified_order", "Latest order verified for the node.", "node")
+	BlocksVerified = NewCounter("qitmeer_blocks_verified_total", "Blocks verified for the node.", "node")
+	SyncRate       = NewGauge("qitmeer_sync_rate_blocks", "Blocks fetched from the node per second since the run started.", "node")
+	VerifyRate     = NewGauge("qitmeer_verify_rate_blocks", "Blocks verified for the node per second since the run started.", "node")
+	ETA            = NewGauge("qitmeer_eta_seconds", "Seconds left until the run verified its last order, -1 while unknown.", "node")
+
+	RPCDuration = NewHistogram("qitmeer_rpc_duration_seconds", "Duration of rpc calls.",
+		[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}, "method", "node")
+	RPCErrors = NewCounter("qitmeer_rpc_errors_total", "Failed rpc calls.", "method", "node")
+
+	Findings   = NewCounter("qitmeer_findings_total", "Failed verifications.", "validator")
+	UtxoSupply = NewGauge("qitmeer_utxo_supply", "Total amount of the unspent outputs.", "node")
+
+	Runs             = NewCounter("qitmeer_runs_total", "Finished runs.")
+	LastRunDuration  = NewGauge("qitmeer_last_run_duration_seconds", "Duration of the last finished run.")
+	LastRunTimestamp = NewGauge("qitmeer_last_run_timestamp_seconds", "Unix time the last run finished.")
+
+	NotificationsDropped = NewCounter("qitmeer_notifications_dropped_total", "Notifications dropped because the queue was full.")
+)
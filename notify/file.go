@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// FileSink appends every message to a file, or writes it to stdout when
+// the path is empty or stdout.
+type FileSink struct {
+	name string
+	out  io.Writer
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" || path == "stdout" {
+		return &FileSink{name: "stdout", out: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{name: path, out: file, file: file}, nil
+}
+
+func (f *FileSink) Name() string {
+	return f.name
+}
+
+// Send writes a header line and the body indented below it, html bodies
+// are written as they are.
+func (f *FileSink) Send(m *Message) error {
+	text := fmt.Sprintf("%s [%s] %s\n", m.Time.Format(time.RFC3339), m.Severity.String(), m.Subject)
+	if body := strings.TrimRight(m.Body, "\n"); body != "" {
+		text += "    " + strings.Replace(body, "\n", "\n    ", -1) + "\n"
+	}
+	_, err := io.WriteString(f.out, text)
+	return err
+}
+
+// Close closes the file, stdout is left open.
+func (f *FileSink) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,269 @@
+package notify
+
+import (
+	"fmt"
+	"github.com/bCoder778/log"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/metrics"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Severity int
+
+const (
+	// Info is progress, like a run starting
+	Info Severity = iota
+	// Notice is the report of a run
+	Notice
+	// Error is a failed verification
+	Error
+)
+
+var severityNames = []string{"info", "notice", "error"}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %s", s)
+}
+
+// Message is one notification, Body is html when HTML is set and plain
+// text otherwise. Messages with a Group are findings, they are throttled
+// and similar ones are summarized together in digests.
+type Message struct {
+	Severity Severity
+	Subject  string
+	Body     string
+	HTML     bool
+	Time     time.Time
+	Group    string
+
+	// done marks the end of a run, it is closed once the run is flushed
+	done chan struct{}
+}
+
+// Sink delivers notifications to one destination, Close releases it once
+// the notifier is done with it.
+type Sink interface {
+	Name() string
+	Send(m *Message) error
+	Close() error
+}
+
+type route struct {
+	sink Sink
+	min  Severity
+}
+
+// Notifier sends every message to the sinks configured for its severity.
+// Messages are delivered in order by one goroutine, so a slow sink does not
+// hold up the verification. When the sinks fall so far behind that the
+// queue is full, messages are dropped and counted instead. Messages sent
+// after Close are dropped too.
+type Notifier struct {
+	routes   []route
+	throttle *throttle
+	queue    chan *Message
+	dropped  int64
+	wg       sync.WaitGroup
+	// mutex guards closed, the queue is only sent to while it is not
+	mutex  sync.RWMutex
+	closed bool
+}
+
+const queue_size = 1000
+
+func NewNotifier(limit *Throttle) *Notifier {
+	n := &Notifier{throttle: newThrottle(limit), queue: make(chan *Message, queue_size)}
+	n.wg.Add(1)
+	go n.deliver()
+	return n
+}
+
+// Add routes the messages of severity min and above to sink.
+func (n *Notifier) Add(sink Sink, min Severity) {
+	n.routes = append(n.routes, route{sink: sink, min: min})
+}
+
+func (n *Notifier) Notify(m *Message) {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if n.closed {
+		log.Warnf("Notifier is closed, drop notification %s", m.Subject)
+		return
+	}
+	select {
+	case n.queue <- m:
+	default:
+		// The first drop of a run is logged, the rest are counted
+		if atomic.AddInt64(&n.dropped, 1) == 1 {
+			log.Warnf("Notification queue is full, drop notification %s and count the ones after it", m.Subject)
+		}
+		metrics.NotificationsDropped.Inc()
+	}
+}
+
+// EndRun sends the pending digest and the count of the suppressed
+// messages of the run, and starts counting the cap again. It returns once
+// they are delivered.
+func (n *Notifier) EndRun() {
+	done := make(chan struct{})
+	n.mutex.RLock()
+	if n.closed {
+		n.mutex.RUnlock()
+		return
+	}
+	n.queue <- &Message{done: done}
+	n.mutex.RUnlock()
+	<-done
+}
+
+func (n *Notifier) deliver() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.throttle.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case m, ok := <-n.queue:
+			if !ok {
+				n.endRun()
+				return
+			}
+			if m.done != nil {
+				n.endRun()
+				close(m.done)
+				continue
+			}
+			n.throttle.handle(m, n.dispatch)
+		case <-ticker.C:
+			n.throttle.tick(n.dispatch)
+		}
+	}
+}
+
+func (n *Notifier) endRun() {
+	n.throttle.endRun(n.dispatch)
+	if dropped := atomic.SwapInt64(&n.dropped, 0); dropped != 0 {
+		n.dispatch(&Message{
+			Severity: Error,
+			Subject:  fmt.Sprintf("%d notifications dropped", dropped),
+			Body:     fmt.Sprintf("The notification queue was full, %d messages were dropped, see the report for every finding.", dropped),
+			Time:     time.Now(),
+		})
+	}
+}
+
+func (n *Notifier) dispatch(m *Message) {
+	for _, r := range n.routes {
+		if m.Severity < r.min {
+			continue
+		}
+		if err := r.sink.Send(m); err != nil {
+			log.Errorf("Send %s notification %s failed, err=%s", r.sink.Name(), m.Subject, err.Error())
+		}
+	}
+}
+
+// Close delivers the queued messages, stops the notifier and closes its
+// sinks. It may be called more than once.
+func (n *Notifier) Close() {
+	n.mutex.Lock()
+	if n.closed {
+		n.mutex.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.queue)
+	n.mutex.Unlock()
+
+	n.wg.Wait()
+	for _, r := range n.routes {
+		if err := r.sink.Close(); err != nil {
+			log.Errorf("Close %s notification sink failed, err=%s", r.sink.Name(), err.Error())
+		}
+	}
+}
+
+// New creates the notifier configured in setting, without any sink every
+// message is mailed as before sinks were configurable.
+func New(setting *conf.Notify, email *conf.Email) (*Notifier, error) {
+	n := NewNotifier(&Throttle{
+		Rate:   setting.Rate,
+		Digest: time.Duration(setting.Digest) * time.Second,
+		Cap:    setting.Cap,
+	})
+	if len(setting.Sinks) == 0 {
+		n.Add(NewSMTPSink(email), Info)
+		return n, nil
+	}
+	for _, s := range setting.Sinks {
+		sink, err := newSink(&s, email)
+		if err == nil {
+			var min Severity
+			if min, err = ParseSeverity(s.Severity); err == nil {
+				n.Add(sink, min)
+				continue
+			}
+		}
+		n.Close()
+		return nil, fmt.Errorf("notify sink %s, %s", s.Type, err.Error())
+	}
+	return n, nil
+}
+
+func newSink(s *conf.Sink, email *conf.Email) (Sink, error) {
+	switch s.Type {
+	case "smtp":
+		return NewSMTPSink(email), nil
+	case "webhook":
+		if s.URL == "" {
+			return nil, fmt.Errorf("webhook needs an url")
+		}
+		return NewWebhookSink(s.URL), nil
+	case "file":
+		return NewFileSink(s.Path)
+	}
+	return nil, fmt.Errorf("unknown sink type")
+}
+
+var defaultNotifier *Notifier
+
+// SetDefault sets the notifier used by Send, nil drops every message.
+func SetDefault(n *Notifier) {
+	defaultNotifier = n
+}
+
+func Send(m *Message) {
+	if defaultNotifier != nil {
+		defaultNotifier.Notify(m)
+	}
+}
+
+// EndRun ends the run of the default notifier.
+func EndRun() {
+	if defaultNotifier != nil {
+		defaultNotifier.EndRun()
+	}
+}
+
+// Sendf sends a plain text message with the default notifier.
+func Sendf(severity Severity, subject string, format string, a ...interface{}) {
+	Send(&Message{Severity: severity, Subject: subject, Body: fmt.Sprintf(format, a...)})
+}
@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink keeps the messages it is sent, block holds up every send
+// until it is closed.
+type recordSink struct {
+	sync.Mutex
+	messages []*Message
+	block    chan struct{}
+	closed   bool
+}
+
+func (r *recordSink) Name() string {
+	return "record"
+}
+
+func (r *recordSink) Send(m *Message) error {
+	if r.block != nil {
+		<-r.block
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func (r *recordSink) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *recordSink) subjects() []string {
+	r.Lock()
+	defer r.Unlock()
+	subjects := make([]string, 0, len(r.messages))
+	for _, m := range r.messages {
+		subjects = append(subjects, m.Subject)
+	}
+	return subjects
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got webhookPayload
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := NewWebhookSink(server.URL).Send(&Message{Severity: Error, Subject: "s", Body: "<b>b</b>", HTML: true, Time: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("content type %q", contentType)
+	}
+	want := webhookPayload{Severity: "error", Subject: "s", Body: "<b>b</b>", HTML: true, Time: at}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWebhookSinkStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := NewWebhookSink(server.URL).Send(&Message{Subject: "s"})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("got %v, want the status", err)
+	}
+}
+
+func TestNotifierRoutes(t *testing.T) {
+	all, errors := &recordSink{}, &recordSink{}
+	n := NewNotifier(&Throttle{})
+	n.Add(all, Info)
+	n.Add(errors, Error)
+	n.Notify(&Message{Severity: Info, Subject: "info"})
+	n.Notify(&Message{Severity: Error, Subject: "error"})
+	n.Close()
+
+	if got := strings.Join(all.subjects(), ","); got != "info,error" {
+		t.Errorf("info sink got %s", got)
+	}
+	if got := strings.Join(errors.subjects(), ","); got != "error" {
+		t.Errorf("error sink got %s", got)
+	}
+}
+
+func TestNotifierDropsWhenFull(t *testing.T) {
+	sink := &recordSink{block: make(chan struct{})}
+	n := NewNotifier(&Throttle{})
+	n.Add(sink, Info)
+
+	// the first message is held by the blocked sink, the rest fill the queue
+	n.Notify(&Message{Subject: "m"})
+	for len(n.queue) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < queue_size+10; i++ {
+			n.Notify(&Message{Subject: "m"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Notify blocked on a full queue")
+	}
+	close(sink.block)
+	n.EndRun()
+
+	subjects := sink.subjects()
+	if last := subjects[len(subjects)-1]; last != "10 notifications dropped" {
+		t.Fatalf("last message %q", last)
+	}
+	if len(subjects) != queue_size+2 {
+		t.Fatalf("got %d messages, want %d", len(subjects), queue_size+2)
+	}
+	n.Close()
+	if got := len(sink.subjects()); got != queue_size+2 {
+		t.Fatalf("the drops were reported again, %d messages", got)
+	}
+}
+
+// A run or a milestone may notify while the daemon shuts down, the
+// notifier drops those messages instead of panicking.
+func TestNotifierAfterClose(t *testing.T) {
+	sink := &recordSink{}
+	n := NewNotifier(&Throttle{})
+	n.Add(sink, Info)
+	n.Notify(&Message{Subject: "before"})
+	n.Close()
+	if !sink.closed {
+		t.Fatal("sink not closed")
+	}
+
+	n.Notify(&Message{Subject: "after"})
+	n.EndRun()
+	n.Close()
+	if subjects := sink.subjects(); len(subjects) != 1 || subjects[0] != "before" {
+		t.Fatalf("got %v", subjects)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notify.log")
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := sink.Send(&Message{Severity: Error, Subject: "s", Body: "a\nb\n", Time: at}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(&Message{Subject: "closed"}); err == nil {
+		t.Fatal("sent to a closed file")
+	}
+	bytes, err := ioutil.ReadFile(path)
+	if want := "2021-01-02T03:04:05Z [error] s\n    a\n    b\n"; err != nil || string(bytes) != want {
+		t.Fatalf("file %q, %v", bytes, err)
+	}
+	if stdout, _ := NewFileSink("stdout"); stdout.Close() != nil {
+		t.Fatal("close stdout failed")
+	}
+}
+
+func TestThrottleRate(t *testing.T) {
+	var sent []*Message
+	send := func(m *Message) { sent = append(sent, m) }
+	th := newThrottle(&Throttle{Rate: 2})
+	for _, group := range []string{"a", "b", "a", "a", "c"} {
+		th.handle(&Message{Severity: Error, Subject: group, Group: group}, send)
+	}
+	th.handle(&Message{Subject: "report"}, send)
+	if len(sent) != 3 || sent[2].Subject != "report" {
+		t.Fatalf("sent %d before the digest", len(sent))
+	}
+	th.tick(send)
+	if len(sent) != 4 {
+		t.Fatalf("no digest sent")
+	}
+	digest := sent[3]
+	if digest.Subject != "Digest of 3 notifications" || digest.Severity != Error {
+		t.Fatalf("digest %q %s", digest.Subject, digest.Severity)
+	}
+	if lines := strings.Split(digest.Body, "\n"); len(lines) != 2 || lines[0] != "a: a and 1 similar, the last a" || lines[1] != "c: c" {
+		t.Fatalf("digest body %q", digest.Body)
+	}
+	// the window starts again after the tick
+	th.handle(&Message{Subject: "d", Group: "d"}, send)
+	if len(sent) != 5 {
+		t.Fatalf("not sent after the tick")
+	}
+}
+
+func TestThrottleCap(t *testing.T) {
+	var sent []*Message
+	send := func(m *Message) { sent = append(sent, m) }
+	th := newThrottle(&Throttle{Cap: 2})
+	for i := 0; i < 5; i++ {
+		th.handle(&Message{Subject: "f", Group: "f"}, send)
+	}
+	if len(sent) != 3 || sent[2].Subject != "Notification cap reached" {
+		t.Fatalf("sent %d", len(sent))
+	}
+	th.endRun(send)
+	if len(sent) != 4 || sent[3].Subject != "3 notifications suppressed" {
+		t.Fatalf("end of run sent %d, last %q", len(sent), sent[len(sent)-1].Subject)
+	}
+	// the cap counts again in the next run
+	th.handle(&Message{Subject: "f", Group: "f"}, send)
+	if len(sent) != 5 || sent[4].Subject != "f" {
+		t.Fatalf("next run sent %d", len(sent))
+	}
+}
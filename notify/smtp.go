@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"github.com/bCoder778/log"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"html"
+	"strings"
+)
+
+// SMTPSink mails the messages with the [email] settings.
+type SMTPSink struct {
+	email *log.EMail
+}
+
+func NewSMTPSink(setting *conf.Email) *SMTPSink {
+	return &SMTPSink{email: log.NewEmail(&log.EMailOption{
+		User:   setting.User,
+		Pass:   setting.Pass,
+		Host:   setting.Host,
+		Port:   setting.Port,
+		Target: setting.To,
+	})}
+}
+
+func (s *SMTPSink) Name() string {
+	return "smtp"
+}
+
+func (s *SMTPSink) Close() error {
+	return nil
+}
+
+// Send mails the body as html, plain text keeps its line breaks.
+func (s *SMTPSink) Send(m *Message) error {
+	body := m.Body
+	if !m.HTML {
+		body = strings.Replace(html.EscapeString(body), "\n", "<br>\n", -1)
+	}
+	return s.email.SendEmail(m.Subject, body)
+}
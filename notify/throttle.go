@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Throttle bounds the notifications of findings. Rate messages a minute
+// are sent right away and the rest wait for the next digest, with Digest
+// set every finding waits for it. Cap bounds the messages of a run, zero
+// values mean no limit.
+type Throttle struct {
+	Rate   int
+	Digest time.Duration
+	Cap    int
+}
+
+const rate_window = time.Minute
+
+type group struct {
+	severity Severity
+	first    string
+	last     string
+	count    int
+}
+
+// throttle is only used by the delivering goroutine.
+type throttle struct {
+	limit      Throttle
+	window     int
+	sent       int
+	suppressed int
+	groups     map[string]*group
+	order      []string
+}
+
+func newThrottle(limit *Throttle) *throttle {
+	return &throttle{limit: *limit, groups: make(map[string]*group)}
+}
+
+func (t *throttle) interval() time.Duration {
+	if t.limit.Digest > 0 {
+		return t.limit.Digest
+	}
+	return rate_window
+}
+
+func (t *throttle) handle(m *Message, send func(*Message)) {
+	if m.Group == "" {
+		send(m)
+		return
+	}
+	if t.capped(send) {
+		return
+	}
+	if t.limit.Digest == 0 && (t.limit.Rate == 0 || t.window < t.limit.Rate) {
+		t.window++
+		t.sent++
+		send(m)
+		return
+	}
+	g, ok := t.groups[m.Group]
+	if !ok {
+		g = &group{severity: m.Severity, first: m.Subject}
+		t.groups[m.Group] = g
+		t.order = append(t.order, m.Group)
+	}
+	if m.Severity > g.severity {
+		g.severity = m.Severity
+	}
+	g.last = m.Subject
+	g.count++
+}
+
+// capped counts the message as suppressed once the run reached the cap,
+// the message that reaches it is replaced by an overflow note.
+func (t *throttle) capped(send func(*Message)) bool {
+	if t.limit.Cap == 0 || t.sent < t.limit.Cap {
+		return false
+	}
+	t.suppressed++
+	if t.suppressed == 1 {
+		send(&Message{
+			Severity: Error,
+			Subject:  "Notification cap reached",
+			Body:     fmt.Sprintf("%d notifications were sent this run, the rest are suppressed until it ends, see the report for every finding.", t.limit.Cap),
+			Time:     time.Now(),
+		})
+	}
+	return true
+}
+
+func (t *throttle) tick(send func(*Message)) {
+	t.window = 0
+	t.flush(send)
+}
+
+// flush sends the pending messages as one digest, every group of similar
+// messages is one line.
+func (t *throttle) flush(send func(*Message)) {
+	if len(t.order) == 0 {
+		return
+	}
+	total := 0
+	severity := Info
+	lines := make([]string, 0, len(t.order))
+	sort.SliceStable(t.order, func(i, j int) bool { return t.groups[t.order[i]].count > t.groups[t.order[j]].count })
+	for _, key := range t.order {
+		g := t.groups[key]
+		total += g.count
+		if g.severity > severity {
+			severity = g.severity
+		}
+		line := fmt.Sprintf("%s: %s", key, g.first)
+		if g.count > 1 {
+			line += fmt.Sprintf(" and %d similar, the last %s", g.count-1, g.last)
+		}
+		lines = append(lines, line)
+	}
+	t.groups = make(map[string]*group)
+	t.order = nil
+
+	if t.capped(send) {
+		t.suppressed += total - 1
+		return
+	}
+	t.sent++
+	send(&Message{
+		Severity: severity,
+		Subject:  fmt.Sprintf("Digest of %d notifications", total),
+		Body:     strings.Join(lines, "\n"),
+		Time:     time.Now(),
+	})
+}
+
+func (t *throttle) endRun(send func(*Message)) {
+	t.flush(send)
+	if t.suppressed != 0 {
+		send(&Message{
+			Severity: Error,
+			Subject:  fmt.Sprintf("%d notifications suppressed", t.suppressed),
+			Body:     fmt.Sprintf("The run reached the cap of %d notifications, %d more were suppressed, see the report for every finding.", t.limit.Cap, t.suppressed),
+			Time:     time.Now(),
+		})
+	}
+	t.window, t.sent, t.suppressed = 0, 0, 0
+}
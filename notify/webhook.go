@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const webhook_timeout = 10 * time.Second
+
+// WebhookSink posts every message as JSON to an url.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+type webhookPayload struct {
+	Severity string    `json:"severity"`
+	Subject  string    `json:"subject"`
+	Body     string    `json:"body"`
+	HTML     bool      `json:"html"`
+	Time     time.Time `json:"time"`
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: webhook_timeout}}
+}
+
+func (w *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookSink) Close() error {
+	return nil
+}
+
+func (w *WebhookSink) Send(m *Message) error {
+	payload, err := json.Marshal(&webhookPayload{
+		Severity: m.Severity.String(),
+		Subject:  m.Subject,
+		Body:     m.Body,
+		HTML:     m.HTML,
+		Time:     m.Time,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded %s", w.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package pow
+
+import (
+	"errors"
+	"math/bits"
+)
+
+const (
+	edge_block_size = 64
+	edge_block_mask = edge_block_size - 1
+)
+
+var (
+	ErrNonceTooBig   = errors.New("circle nonce too big")
+	ErrNonceTooSmall = errors.New("circle nonces not ascending")
+	ErrNonMatching   = errors.New("circle endpoints do not match up")
+	ErrBranch        = errors.New("circle branches")
+	ErrDeadEnd       = errors.New("circle dead ends")
+	ErrShortCycle    = errors.New("circle is shorter than proof size")
+)
+
+type sipState [4]uint64
+
+func (s *sipState) round() {
+	s[0] += s[1]
+	s[2] += s[3]
+	s[1] = bits.RotateLeft64(s[1], 13)
+	s[3] = bits.RotateLeft64(s[3], 16)
+	s[1] ^= s[0]
+	s[3] ^= s[2]
+	s[0] = bits.RotateLeft64(s[0], 32)
+	s[2] += s[1]
+	s[0] += s[3]
+	s[1] = bits.RotateLeft64(s[1], 17)
+	s[3] = bits.RotateLeft64(s[3], 21)
+	s[1] ^= s[2]
+	s[3] ^= s[0]
+	s[2] = bits.RotateLeft64(s[2], 32)
+}
+
+func (s *sipState) hash24(nonce uint64) {
+	s[3] ^= nonce
+	s.round()
+	s.round()
+	s[0] ^= nonce
+	s[2] ^= 0xff
+	s.round()
+	s.round()
+	s.round()
+	s.round()
+}
+
+func (s *sipState) digest() uint64 {
+	return s[0] ^ s[1] ^ s[2] ^ s[3]
+}
+
+func SipHash24(keys [4]uint64, nonce uint64) uint64 {
+	s := sipState(keys)
+	s.hash24(nonce)
+	return s.digest()
+}
+
+// SipBlock hashes the whole block of 64 edges containing nonce with a
+// running siphash state, as cuckaroo does.
+func SipBlock(keys [4]uint64, nonce uint64) uint64 {
+	var buf [edge_block_size]uint64
+	s := sipState(keys)
+	start := nonce &^ edge_block_mask
+	for i := range buf {
+		s.hash24(start + uint64(i))
+		buf[i] = s.digest()
+	}
+	idx := nonce & edge_block_mask
+	if idx == edge_block_mask {
+		return buf[idx]
+	}
+	return buf[idx] ^ buf[edge_block_mask]
+}
+
+func verifyCuckaroo(keys [4]uint64, nonces []uint32, edgeBits uint) error {
+	edgeMask := uint64(1)<<edgeBits - 1
+	uvs := make([]uint64, 2*ProofSize)
+	var xor0, xor1 uint64
+	for n := 0; n < ProofSize; n++ {
+		if uint64(nonces[n]) > edgeMask {
+			return ErrNonceTooBig
+		}
+		if n > 0 && nonces[n] <= nonces[n-1] {
+			return ErrNonceTooSmall
+		}
+		edge := SipBlock(keys, uint64(nonces[n]))
+		uvs[2*n] = edge & edgeMask
+		uvs[2*n+1] = (edge >> 32) & edgeMask
+		xor0 ^= uvs[2*n]
+		xor1 ^= uvs[2*n+1]
+	}
+	if xor0|xor1 != 0 {
+		return ErrNonMatching
+	}
+	return followCycle(uvs, func(a, b uint64) bool { return a == b }, false)
+}
+
+func verifyCuckatoo(keys [4]uint64, nonces []uint32, edgeBits uint) error {
+	edgeMask := uint64(1)<<edgeBits - 1
+	uvs := make([]uint64, 2*ProofSize)
+	var xor0, xor1 uint64
+	for n := 0; n < ProofSize; n++ {
+		if uint64(nonces[n]) > edgeMask {
+			return ErrNonceTooBig
+		}
+		if n > 0 && nonces[n] <= nonces[n-1] {
+			return ErrNonceTooSmall
+		}
+		uvs[2*n] = SipHash24(keys, 2*uint64(nonces[n])) & edgeMask
+		uvs[2*n+1] = SipHash24(keys, 2*uint64(nonces[n])+1) & edgeMask
+		xor0 ^= uvs[2*n]
+		xor1 ^= uvs[2*n+1]
+	}
+	// Cuckatoo nodes carry no partition bit, a cycle steps from node x to
+	// x^1 instead. Every pair on a side flips the lowest bit of the xor,
+	// so it ends up as the parity of the number of pairs.
+	odd := uint64(ProofSize/2) & 1
+	if xor0 != odd || xor1 != odd {
+		return ErrNonMatching
+	}
+	return followCycle(uvs, func(a, b uint64) bool { return a>>1 == b>>1 }, true)
+}
+
+// followCycle walks the edges from the first endpoint and checks that they
+// form a single cycle of ProofSize edges. With strict set matching
+// endpoints must be distinct, as cuckatoo pairs x with x^1.
+func followCycle(uvs []uint64, match func(a, b uint64) bool, strict bool) error {
+	n, i := 0, 0
+	for {
+		j := i
+		for k := (i + 2) % len(uvs); k != i; k = (k + 2) % len(uvs) {
+			if match(uvs[k], uvs[i]) {
+				if j != i {
+					return ErrBranch
+				}
+				j = k
+			}
+		}
+		if j == i || (strict && uvs[j] == uvs[i]) {
+			return ErrDeadEnd
+		}
+		i = j ^ 1
+		n++
+		if i == 0 {
+			break
+		}
+	}
+	if n != ProofSize {
+		return ErrShortCycle
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package pow
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// HeaderLength is the serialized size of a qitmeer block header:
+// version, parent root, tx root, state root, difficulty, timestamp,
+// pow type, nonce and proof data.
+const HeaderLength = 4 + 32*3 + 4 + 4 + 1 + 8 + ProofDataLength
+
+type Header struct {
+	Version      uint32
+	Difficulty   uint32
+	Timestamp    uint32
+	PowType      PowType
+	Nonce        uint64
+	EdgeBits     int
+	CircleNonces []uint32
+	raw          []byte
+}
+
+func ParseHeader(s string) (*Header, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header hex, %s", err.Error())
+	}
+	if len(raw) != HeaderLength {
+		return nil, fmt.Errorf("invalid header length %d, need %d", len(raw), HeaderLength)
+	}
+	h := &Header{raw: raw}
+	h.Version = binary.LittleEndian.Uint32(raw[0:])
+	pos := 4 + 32*3
+	h.Difficulty = binary.LittleEndian.Uint32(raw[pos:])
+	h.Timestamp = binary.LittleEndian.Uint32(raw[pos+4:])
+	pos += 8
+	h.PowType = PowType(raw[pos])
+	h.Nonce = binary.LittleEndian.Uint64(raw[pos+1:])
+	pos += 9
+	h.EdgeBits = int(raw[pos])
+	h.CircleNonces = make([]uint32, ProofSize)
+	for i := range h.CircleNonces {
+		h.CircleNonces[i] = binary.LittleEndian.Uint32(raw[pos+1+i*4:])
+	}
+	return h, nil
+}
+
+// BlockData is the header without proof data, it is what the cuckoo
+// algorithms are solved for.
+func (h *Header) BlockData() []byte {
+	return h.raw[:len(h.raw)-ProofDataLength]
+}
+
+// Hash is the double blake2b hash of the header in rpc display order.
+func (h *Header) Hash() string {
+	first := blake2b.Sum256(h.raw)
+	second := blake2b.Sum256(first[:])
+	for i, j := 0, len(second)-1; i < j; i, j = i+1, j-1 {
+		second[i], second[j] = second[j], second[i]
+	}
+	return hex.EncodeToString(second[:])
+}
@@ -0,0 +1,215 @@
+package pow
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+type PowType int
+
+const (
+	BLAKE2BD PowType = 0
+	CUCKAROO PowType = 1
+	CUCKATOO PowType = 2
+)
+
+const (
+	ProofSize       = 42
+	ProofDataLength = 1 + ProofSize*4
+
+	min_cuckaroo_edgebits = 24
+	min_cuckatoo_edgebits = 29
+	max_edgebits          = 63
+)
+
+func (p PowType) String() string {
+	switch p {
+	case BLAKE2BD:
+		return "blake2bd"
+	case CUCKAROO:
+		return "cuckaroo"
+	case CUCKATOO:
+		return "cuckatoo"
+	}
+	return fmt.Sprintf("unknown(%d)", int(p))
+}
+
+// Proof is the proof of work carried by a block together with the block
+// data it was solved for.
+type Proof struct {
+	Type         PowType
+	Nonce        uint64
+	EdgeBits     int
+	CircleNonces []uint32
+	// Hash is the block hash as shown by rpc, it is used as the
+	// blake2bd pow hash.
+	Hash string
+	// Header is the serialized block header, it is required to derive the
+	// siphash keys of the cuckoo algorithms.
+	Header *Header
+}
+
+// Verify verifies the proof against the target encoded in bits.
+func Verify(p *Proof, bits uint32) error {
+	switch p.Type {
+	case BLAKE2BD:
+		return verifyBlake2bd(p, bits)
+	case CUCKAROO:
+		return verifyCuckoo(p, bits, min_cuckaroo_edgebits, verifyCuckaroo)
+	case CUCKATOO:
+		return verifyCuckoo(p, bits, min_cuckatoo_edgebits, verifyCuckatoo)
+	}
+	return fmt.Errorf("unsupported pow type %d", p.Type)
+}
+
+func verifyBlake2bd(p *Proof, bits uint32) error {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return fmt.Errorf("target %064x from bits %08x is not positive", target, bits)
+	}
+	h, ok := new(big.Int).SetString(p.Hash, 16)
+	if !ok {
+		return fmt.Errorf("invalid block hash %s", p.Hash)
+	}
+	if h.Cmp(target) > 0 {
+		return fmt.Errorf("block hash %s is higher than target %064x", p.Hash, target)
+	}
+	return nil
+}
+
+func verifyCuckoo(p *Proof, bits uint32, minEdgeBits int, verifyCycle func(keys [4]uint64, nonces []uint32, edgeBits uint) error) error {
+	if p.EdgeBits < minEdgeBits || p.EdgeBits > max_edgebits {
+		return fmt.Errorf("edge bits %d out of range [%d, %d]", p.EdgeBits, minEdgeBits, max_edgebits)
+	}
+	if len(p.CircleNonces) != ProofSize {
+		return fmt.Errorf("circle nonces count %d, need %d", len(p.CircleNonces), ProofSize)
+	}
+	if p.Header == nil {
+		return errors.New("missing block header")
+	}
+	if err := verifyCycle(SipHashKeys(p.Header.BlockData()), p.CircleNonces, uint(p.EdgeBits)); err != nil {
+		return err
+	}
+	target := CompactToBig(bits)
+	diff := CalcCuckooDiff(GraphWeight(p.EdgeBits, minEdgeBits), CircleNoncesHash(p.CircleNonces))
+	if diff.Cmp(target) < 0 {
+		return fmt.Errorf("cuckoo difficulty %s is lower than target %s", diff.String(), target.String())
+	}
+	return nil
+}
+
+// ParseBits parses the hex encoded compact target returned by rpc.
+func ParseBits(bits string) (uint32, error) {
+	v, err := strconv.ParseUint(bits, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bits %s, %s", bits, err.Error())
+	}
+	return uint32(v), nil
+}
+
+// ParseCircleNonces parses the hex encoded proof data returned by rpc, each
+// nonce is a little endian uint32.
+func ParseCircleNonces(s string) ([]uint32, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid circle nonces, %s", err.Error())
+	}
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("invalid circle nonces length %d", len(b))
+	}
+	nonces := make([]uint32, len(b)/4)
+	for i := range nonces {
+		nonces[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return nonces, nil
+}
+
+// CompactToBig converts a compact representation of a whole number to an
+// unsigned big integer, the same way as bitcoin does.
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+	return bn
+}
+
+// BigToCompact converts a whole number to its compact representation.
+func BigToCompact(n *big.Int) uint32 {
+	if n.Sign() == 0 {
+		return 0
+	}
+	var mantissa uint32
+	exponent := uint(len(n.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(n.Bits()[0])
+		mantissa <<= 8 * (3 - exponent)
+	} else {
+		tn := new(big.Int).Set(n)
+		mantissa = uint32(tn.Rsh(tn, 8*(exponent-3)).Bits()[0])
+	}
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
+	}
+	return compact
+}
+
+// GraphWeight is the scale applied to cuckoo difficulties so that larger
+// graphs are rewarded for the extra work.
+func GraphWeight(edgeBits, minEdgeBits int) uint64 {
+	return (2 << uint(edgeBits-minEdgeBits)) * uint64(edgeBits)
+}
+
+// CircleNoncesHash is the blake2b hash of the little endian circle nonces.
+func CircleNoncesHash(nonces []uint32) [32]byte {
+	b := make([]byte, len(nonces)*4)
+	for i, n := range nonces {
+		binary.LittleEndian.PutUint32(b[i*4:], n)
+	}
+	return blake2b.Sum256(b)
+}
+
+// CalcCuckooDiff computes scale * 2^64 / the first 8 bytes of the hash.
+func CalcCuckooDiff(scale uint64, h [32]byte) *big.Int {
+	v := binary.BigEndian.Uint64(h[:8])
+	if v == 0 {
+		v = 1
+	}
+	d := new(big.Int).Lsh(big.NewInt(1), 64)
+	d.Div(d, new(big.Int).SetUint64(v))
+	return d.Mul(d, new(big.Int).SetUint64(scale))
+}
+
+// SipHashKeys derives the siphash keys from the blake2b hash of the block
+// data.
+func SipHashKeys(data []byte) [4]uint64 {
+	h := blake2b.Sum256(data)
+	return [4]uint64{
+		binary.LittleEndian.Uint64(h[0:]),
+		binary.LittleEndian.Uint64(h[8:]),
+		binary.LittleEndian.Uint64(h[16:]),
+		binary.LittleEndian.Uint64(h[24:]),
+	}
+}
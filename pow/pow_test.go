@@ -0,0 +1,138 @@
+package pow
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// The cycle vectors were found by a solver walking the graphs of the keys at
+// 12 and 14 edge bits, the full sizes are too large to solve for a test.
+var (
+	cuckarooKeys   = [4]uint64{0x3fcd1e15e67972f4, 0x736f6d6570736551, 0x45c, 0x2b}
+	cuckarooBits   = uint(14)
+	cuckarooNonces = []uint32{0x61, 0xc7, 0x25e, 0x285, 0x33d, 0x372, 0x5d2, 0x701, 0x7cb, 0x802, 0xa92, 0xc18,
+		0x193c, 0x1945, 0x19e0, 0x1c29, 0x1e3b, 0x1e9c, 0x211b, 0x2176, 0x22b0, 0x23ea, 0x2810, 0x2bd2, 0x2ca6,
+		0x2ded, 0x2dfe, 0x2f7a, 0x3108, 0x32d4, 0x338b, 0x3506, 0x38d4, 0x39a5, 0x39b0, 0x3a92, 0x3b05, 0x3b1b,
+		0x3bae, 0x3dec, 0x3ebd, 0x3ff8}
+
+	cuckatooKeys   = [4]uint64{0xcce004cd52fe4404, 0x736f6d6570736581, 0x1d8c, 0xfb}
+	cuckatooBits   = uint(12)
+	cuckatooNonces = []uint32{0x13f, 0x15a, 0x194, 0x1a9, 0x1fa, 0x2c8, 0x2f4, 0x339, 0x33d, 0x3d2, 0x3ef, 0x419,
+		0x46f, 0x476, 0x4a7, 0x4d3, 0x515, 0x552, 0x570, 0x5b5, 0x652, 0x76e, 0x7ac, 0x89c, 0x8b0, 0x922, 0x973,
+		0x979, 0x9b2, 0x9b7, 0x9c1, 0xa5d, 0xaae, 0xb50, 0xb71, 0xc3f, 0xce2, 0xd16, 0xd57, 0xde3, 0xf0d, 0xf3d}
+)
+
+type cycleCase struct {
+	name   string
+	keys   [4]uint64
+	nonces []uint32
+	err    error
+}
+
+// badCycles derives broken proofs from a good one.
+func badCycles(keys [4]uint64, nonces []uint32, edgeBits uint) []cycleCase {
+	edit := func(fn func(ns []uint32)) []uint32 {
+		ns := append([]uint32(nil), nonces...)
+		fn(ns)
+		return ns
+	}
+	otherKeys := keys
+	otherKeys[3]++
+	return []cycleCase{
+		{"too big", keys, edit(func(ns []uint32) { ns[ProofSize-1] = 1 << edgeBits }), ErrNonceTooBig},
+		{"not ascending", keys, edit(func(ns []uint32) { ns[0], ns[1] = ns[1], ns[0] }), ErrNonceTooSmall},
+		{"duplicate", keys, edit(func(ns []uint32) { ns[1] = ns[0] }), ErrNonceTooSmall},
+		{"other edge", keys, edit(func(ns []uint32) { ns[0] = 0 }), nil},
+		{"other keys", otherKeys, nonces, nil},
+	}
+}
+
+func testCycles(t *testing.T, verify func([4]uint64, []uint32, uint) error, keys [4]uint64, nonces []uint32, edgeBits uint) {
+	if err := verify(keys, nonces, edgeBits); err != nil {
+		t.Fatalf("good proof rejected, %v", err)
+	}
+	for _, c := range badCycles(keys, nonces, edgeBits) {
+		err := verify(c.keys, c.nonces, edgeBits)
+		if err == nil {
+			t.Errorf("%s: bad proof accepted", c.name)
+		} else if c.err != nil && err != c.err {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.err)
+		}
+	}
+}
+
+func TestVerifyCuckaroo(t *testing.T) {
+	testCycles(t, verifyCuckaroo, cuckarooKeys, cuckarooNonces, cuckarooBits)
+}
+
+func TestVerifyCuckatoo(t *testing.T) {
+	testCycles(t, verifyCuckatoo, cuckatooKeys, cuckatooNonces, cuckatooBits)
+}
+
+// A cuckaroo proof is no cuckatoo proof and the other way round.
+func TestCycleAlgorithmsDiffer(t *testing.T) {
+	if verifyCuckatoo(cuckarooKeys, cuckarooNonces, cuckarooBits) == nil {
+		t.Error("cuckaroo proof accepted as cuckatoo")
+	}
+	if verifyCuckaroo(cuckatooKeys, cuckatooNonces, cuckatooBits) == nil {
+		t.Error("cuckatoo proof accepted as cuckaroo")
+	}
+}
+
+func TestVerifyBlake2bd(t *testing.T) {
+	const bits = 0x1e0fffff
+	target := CompactToBig(bits)
+	below := new(big.Int).Sub(target, big.NewInt(1))
+	above := new(big.Int).Add(target, big.NewInt(1))
+	cases := []struct {
+		name string
+		hash string
+		ok   bool
+	}{
+		{"below target", below.Text(16), true},
+		{"at target", target.Text(16), true},
+		{"above target", above.Text(16), false},
+		{"max hash", strings.Repeat("f", 64), false},
+		{"not hex", "xyz", false},
+	}
+	for _, c := range cases {
+		err := Verify(&Proof{Type: BLAKE2BD, Hash: c.hash}, bits)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+	if Verify(&Proof{Type: BLAKE2BD, Hash: "00"}, 0) == nil {
+		t.Error("zero target accepted")
+	}
+}
+
+func TestVerifyCuckooParams(t *testing.T) {
+	header := &Header{raw: make([]byte, HeaderLength)}
+	cases := []struct {
+		name  string
+		proof *Proof
+	}{
+		{"cuckaroo small graph", &Proof{Type: CUCKAROO, EdgeBits: min_cuckaroo_edgebits - 1, CircleNonces: cuckarooNonces, Header: header}},
+		{"cuckatoo small graph", &Proof{Type: CUCKATOO, EdgeBits: min_cuckatoo_edgebits - 1, CircleNonces: cuckatooNonces, Header: header}},
+		{"short proof", &Proof{Type: CUCKAROO, EdgeBits: min_cuckaroo_edgebits, CircleNonces: cuckarooNonces[1:], Header: header}},
+		{"no header", &Proof{Type: CUCKAROO, EdgeBits: min_cuckaroo_edgebits, CircleNonces: cuckarooNonces}},
+		{"unknown type", &Proof{Type: 7}},
+	}
+	for _, c := range cases {
+		if Verify(c.proof, 0x2100ffff) == nil {
+			t.Errorf("%s: accepted", c.name)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	for _, bits := range []uint32{0x1d00ffff, 0x1e0fffff, 0x2100ffff, 0x03123456, 0x01120000} {
+		if got := BigToCompact(CompactToBig(bits)); got != bits {
+			t.Errorf("compact %08x round trips to %08x", bits, got)
+		}
+	}
+	if CompactToBig(0x1d00ffff).Text(16) != "ffff0000000000000000000000000000000000000000000000000000" {
+		t.Errorf("compact 1d00ffff is %x", CompactToBig(0x1d00ffff))
+	}
+}
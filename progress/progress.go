@@ -0,0 +1,152 @@
+package progress
+
+import (
+	"fmt"
+	"github.com/bCoder778/log"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/metrics"
+	"github.com/bCoder778/qitmeer_test/notify"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tracker follows how far one node got in a run, from fetching the blocks
+// to verifying them. It is safe to use from the sync and check goroutines.
+type Tracker struct {
+	mutex      sync.Mutex
+	node       string
+	first      uint64
+	last       uint64
+	start      time.Time
+	fetched    uint64
+	verified   uint64
+	fetchedAt  uint64
+	verifiedAt uint64
+	logged     time.Time
+	milestones []int
+}
+
+// Status is a snapshot of a tracker. Orders are the latest fetched and
+// verified, rates are blocks per second since the run started and ETA is
+// the seconds left at the verify rate, -1 while it is unknown.
+type Status struct {
+	Node         string  `json:"node"`
+	FirstOrder   uint64  `json:"firstOrder"`
+	LastOrder    uint64  `json:"lastOrder"`
+	Fetched      uint64  `json:"fetched"`
+	Verified     uint64  `json:"verified"`
+	FetchedOrder uint64  `json:"fetchedOrder"`
+	Order        uint64  `json:"order"`
+	FetchRate    float64 `json:"fetchRate"`
+	VerifyRate   float64 `json:"verifyRate"`
+	Percent      float64 `json:"percent"`
+	ETA          int64   `json:"eta"`
+	Seconds      int64   `json:"seconds"`
+}
+
+// New tracks node over the orders first to last.
+func New(node string, first, last uint64) *Tracker {
+	milestones := append([]int(nil), conf.Setting.Progress.Milestones...)
+	sort.Ints(milestones)
+	now := time.Now()
+	return &Tracker{node: node, first: first, last: last, start: now, logged: now, milestones: milestones}
+}
+
+func (t *Tracker) Node() string {
+	return t.node
+}
+
+// Fetched records a block fetched from the node.
+func (t *Tracker) Fetched(order uint64) {
+	t.mutex.Lock()
+	t.fetched++
+	t.fetchedAt = order
+	s := t.status()
+	t.mutex.Unlock()
+
+	metrics.FetchedOrder.Set(float64(order), t.node)
+	metrics.SyncRate.Set(s.FetchRate, t.node)
+}
+
+// Verified records a block of the node verified, it logs the progress
+// every loginterval and notifies the milestones passed.
+func (t *Tracker) Verified(order uint64) {
+	t.mutex.Lock()
+	t.verified++
+	t.verifiedAt = order
+	s := t.status()
+	interval := time.Duration(conf.Setting.Progress.LogInterval) * time.Second
+	logNow := interval > 0 && time.Since(t.logged) >= interval
+	if logNow {
+		t.logged = time.Now()
+	}
+	passed := 0
+	for len(t.milestones) != 0 && s.Percent >= float64(t.milestones[0]) {
+		passed = t.milestones[0]
+		t.milestones = t.milestones[1:]
+	}
+	t.mutex.Unlock()
+
+	metrics.VerifiedOrder.Set(float64(order), t.node)
+	metrics.BlocksVerified.Inc(t.node)
+	metrics.VerifyRate.Set(s.VerifyRate, t.node)
+	metrics.ETA.Set(float64(s.ETA), t.node)
+	if logNow {
+		log.Infof("%s", s.String())
+	}
+	if passed != 0 {
+		notify.Send(&notify.Message{Severity: notify.Info,
+			Subject: fmt.Sprintf("Test qitmeer %s passed %d%%", t.node, passed), Body: s.String()})
+	}
+}
+
+func (t *Tracker) Status() Status {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.status()
+}
+
+func (t *Tracker) status() Status {
+	s := Status{
+		Node:         t.node,
+		FirstOrder:   t.first,
+		LastOrder:    t.last,
+		Fetched:      t.fetched,
+		Verified:     t.verified,
+		FetchedOrder: t.fetchedAt,
+		Order:        t.verifiedAt,
+		ETA:          -1,
+	}
+	elapsed := time.Since(t.start).Seconds()
+	s.Seconds = int64(elapsed)
+	if elapsed > 0 {
+		s.FetchRate = float64(t.fetched) / elapsed
+		s.VerifyRate = float64(t.verified) / elapsed
+	}
+	total := uint64(0)
+	if t.last >= t.first {
+		total = t.last - t.first + 1
+	}
+	if total == 0 {
+		s.Percent = 100
+	} else {
+		s.Percent = float64(t.verified) * 100 / float64(total)
+	}
+	switch {
+	case t.verified >= total:
+		s.ETA = 0
+	case s.VerifyRate > 0:
+		s.ETA = int64(float64(total-t.verified) / s.VerifyRate)
+	}
+	return s
+}
+
+func (s Status) String() string {
+	eta := "unknown"
+	if s.ETA >= 0 {
+		eta = (time.Duration(s.ETA) * time.Second).String()
+	}
+	return fmt.Sprintf("%s progress order %d of %d to %d (%.2f%%), fetched %d, fetch %.2f blocks/s, verify %.2f blocks/s, eta %s",
+		s.Node, s.Order, s.FirstOrder, s.LastOrder, s.Percent, s.FetchedOrder, s.FetchRate, s.VerifyRate, eta)
+}
@@ -0,0 +1,112 @@
+package progress
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/notify"
+)
+
+// recordSink keeps the subjects of the messages it is sent.
+type recordSink struct {
+	sync.Mutex
+	subjects []string
+}
+
+func (r *recordSink) Name() string {
+	return "record"
+}
+
+func (r *recordSink) Send(m *notify.Message) error {
+	r.Lock()
+	defer r.Unlock()
+	r.subjects = append(r.subjects, m.Subject)
+	return nil
+}
+
+func (r *recordSink) Close() error {
+	return nil
+}
+
+// milestones runs fn with the milestones set and returns the subjects it
+// notified.
+func milestones(t *testing.T, set []int, fn func()) []string {
+	defer func(progress conf.Progress) { conf.Setting.Progress = progress }(conf.Setting.Progress)
+	conf.Setting.Progress = conf.Progress{Milestones: set}
+
+	sink := &recordSink{}
+	n := notify.NewNotifier(&notify.Throttle{})
+	n.Add(sink, notify.Info)
+	notify.SetDefault(n)
+	defer notify.SetDefault(nil)
+
+	fn()
+	n.Close()
+	return sink.subjects
+}
+
+func TestStatus(t *testing.T) {
+	tracker := New("release", 10, 19)
+	tracker.start = time.Now().Add(-10 * time.Second)
+	if s := tracker.Status(); s.Percent != 0 || s.ETA != -1 {
+		t.Fatalf("percent %v eta %d before any block", s.Percent, s.ETA)
+	}
+	for order := uint64(10); order < 15; order++ {
+		tracker.Fetched(order)
+		tracker.Verified(order)
+	}
+	s := tracker.Status()
+	if s.Percent != 50 || s.Order != 14 || s.FetchedOrder != 14 || s.Verified != 5 {
+		t.Fatalf("status %+v", s)
+	}
+	// 5 blocks in 10 seconds, the 5 left take 10 more
+	if s.ETA < 9 || s.ETA > 10 {
+		t.Fatalf("eta %d", s.ETA)
+	}
+	for order := uint64(15); order < 20; order++ {
+		tracker.Verified(order)
+	}
+	if s := tracker.Status(); s.Percent != 100 || s.ETA != 0 {
+		t.Fatalf("percent %v eta %d when done", s.Percent, s.ETA)
+	}
+}
+
+func TestMilestones(t *testing.T) {
+	subjects := milestones(t, []int{50, 25, 100}, func() {
+		tracker := New("test", 1, 4)
+		for order := uint64(1); order <= 4; order++ {
+			tracker.Verified(order)
+		}
+		// blocks verified again past the end do not notify again
+		tracker.Verified(4)
+	})
+	want := []string{"Test qitmeer test passed 25%", "Test qitmeer test passed 50%", "Test qitmeer test passed 100%"}
+	if len(subjects) != len(want) {
+		t.Fatalf("subjects %v", subjects)
+	}
+	for i := range want {
+		if subjects[i] != want[i] {
+			t.Fatalf("subjects %v", subjects)
+		}
+	}
+}
+
+// A run with nothing to verify is done from the start, it passes every
+// milestone once.
+func TestNothingToVerify(t *testing.T) {
+	var s Status
+	subjects := milestones(t, []int{25, 50}, func() {
+		tracker := New("release", 10, 9)
+		s = tracker.Status()
+		tracker.Verified(10)
+		tracker.Verified(11)
+	})
+	if s.Percent != 100 || s.ETA != 0 {
+		t.Fatalf("percent %v eta %d", s.Percent, s.ETA)
+	}
+	if len(subjects) != 1 || subjects[0] != "Test qitmeer release passed 50%" {
+		t.Fatalf("subjects %v", subjects)
+	}
+}
@@ -0,0 +1,206 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	chart_width   = 640
+	chart_height  = 200
+	chart_padding = 40
+	// section_findings bounds the findings listed per validator, recurring
+	// failures of a fork can be thousands
+	section_findings = 20
+)
+
+var chart_colors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b"}
+
+// The report only uses inline styles and inline svg, so the same document
+// works as an email body and as a saved file.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"chart":   chart,
+	"diffs":   diffs,
+	"failed":  failed,
+	"section": section,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Qitmeer test report {{.Run.ID}}</title></head>
+<body style="font-family:Helvetica,Arial,sans-serif;font-size:14px;color:#222;margin:16px">
+<h2 style="margin:0 0 8px">Qitmeer test report{{if .Run.ID}} #{{.Run.ID}}{{end}}</h2>
+<p style="margin:0 0 16px;color:#555">{{.Run.Start.Format "2006-01-02 15:04:05"}}, {{.Run.Seconds}}s, orders {{.Run.FirstOrder}} to {{.Run.LastOrder}}{{with .Run.Snapshot}}, seeded with snapshot order {{.Order}} sha256 {{.Sha256}}{{end}}{{if .Run.Canceled}}, <b style="color:#c00">canceled</b>{{end}}</p>
+
+<table style="border-collapse:collapse;margin-bottom:16px">
+<tr style="background:#eee"><th style="padding:4px 8px;text-align:left">Node</th><th style="padding:4px 8px;text-align:left">Version</th><th style="padding:4px 8px;text-align:right">Blocks</th><th style="padding:4px 8px;text-align:right">Utxo</th><th style="padding:4px 8px;text-align:right">Fetched/s</th><th style="padding:4px 8px;text-align:right">Verified/s</th></tr>
+{{range .Nodes}}<tr><td style="padding:4px 8px">{{.Role}}</td><td style="padding:4px 8px">{{.Version}}</td><td style="padding:4px 8px;text-align:right">{{.Blocks}}</td><td style="padding:4px 8px;text-align:right">{{.Utxo}}</td><td style="padding:4px 8px;text-align:right">{{printf "%.2f" .FetchRate}}</td><td style="padding:4px 8px;text-align:right">{{printf "%.2f" .VerifyRate}}</td></tr>
+{{end}}</table>
+
+<table style="border-collapse:collapse;margin-bottom:16px">
+<tr style="background:#eee"><th style="padding:4px 8px;text-align:left">Validator</th><th style="padding:4px 8px;text-align:right">Checked</th><th style="padding:4px 8px;text-align:right">Failed</th><th style="padding:4px 8px;text-align:left">Counters</th></tr>
+{{range .Validators}}<tr><td style="padding:4px 8px">{{.Name}}</td><td style="padding:4px 8px;text-align:right">{{.Checked}}</td><td style="padding:4px 8px;text-align:right;{{if .Failed}}color:#c00;font-weight:bold{{end}}">{{.Failed}}</td><td style="padding:4px 8px;color:#555">{{range $k, $v := .Counters}}{{$k}}={{$v}} {{end}}{{.Note}}</td></tr>
+{{end}}</table>
+
+<h3>New findings ({{len (failed .Findings "new")}})</h3>
+{{range failed .Findings "new"}}{{template "finding" .}}{{else}}<p style="color:#555">None.</p>{{end}}
+
+<h3>Resolved findings ({{len .Resolved}})</h3>
+{{range .Resolved}}{{template "finding" .}}{{else}}<p style="color:#555">None.</p>{{end}}
+
+{{$r := .}}{{range .Validators}}{{with section $r.Findings .Name}}
+<h3>{{.Name}}, other findings ({{.Total}})</h3>
+{{range .Findings}}{{template "finding" .}}{{end}}{{if .More}}<p style="color:#555">{{.More}} more in the json report.</p>{{end}}{{end}}{{end}}
+
+<h3>Supply</h3>
+{{chart "utxo total by order" .Nodes "supply"}}
+<h3>Block intervals</h3>
+{{chart "seconds to the previous order" .Nodes "intervals"}}
+</body>
+</html>
+{{define "finding"}}<div style="border-left:3px solid {{if eq .Status "resolved"}}#2ca02c{{else if eq .Status "new"}}#c00{{else}}#999{{end}};padding:4px 8px;margin:0 0 8px">
+<div><b>{{.Validator}}</b> {{.Kind}} order {{.Order}} {{if .Hash}}<code>{{.Hash}}</code>{{end}} <span style="color:#555">[{{.ID}}]{{if .Status}} {{.Status}}{{end}}{{if .Acked}}, acknowledged{{end}}{{if .FirstRun}}, first run {{.FirstRun}}{{end}}</span></div>
+<div style="color:#444">{{.Message}}</div>
+{{with .Fields}}<table style="border-collapse:collapse;margin-top:4px">
+<tr style="background:#eee"><th style="padding:2px 8px;text-align:left">Field</th><th style="padding:2px 8px;text-align:left">Release</th><th style="padding:2px 8px;text-align:left">Test</th></tr>
+{{range diffs .}}<tr{{if .Differ}} style="background:#fdd"{{end}}><td style="padding:2px 8px">{{.Name}}</td><td style="padding:2px 8px;font-family:monospace">{{.Release}}</td><td style="padding:2px 8px;font-family:monospace">{{.Test}}</td></tr>
+{{end}}</table>{{end}}
+</div>{{end}}`))
+
+// HTML renders the report as a self-contained html document.
+func (r *Report) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteHTML writes the html report to dir and returns the path of the file.
+func WriteHTML(dir string, r *Report) (string, error) {
+	bytes, err := r.HTML()
+	if err != nil {
+		return "", err
+	}
+	return write(dir, r.Name()+".html", bytes)
+}
+
+func failed(findings []Finding, status string) []Finding {
+	rs := make([]Finding, 0)
+	for _, f := range findings {
+		if f.Status == status {
+			rs = append(rs, f)
+		}
+	}
+	return rs
+}
+
+type validatorSection struct {
+	Name     string
+	Total    int
+	More     int
+	Findings []Finding
+}
+
+// section collects the findings of the validator that are not new, which
+// are listed on their own, nil when there are none.
+func section(findings []Finding, name string) *validatorSection {
+	s := &validatorSection{Name: name, Findings: make([]Finding, 0)}
+	for _, f := range findings {
+		if f.Validator != name || f.Status == "new" {
+			continue
+		}
+		s.Total++
+		if len(s.Findings) < section_findings {
+			s.Findings = append(s.Findings, f)
+		}
+	}
+	if s.Total == 0 {
+		return nil
+	}
+	s.More = s.Total - len(s.Findings)
+	return s
+}
+
+type fieldDiff struct {
+	Field
+	Differ bool
+}
+
+// diffs marks the fields whose release and test values disagree, a field
+// found on one node only is not a disagreement.
+func diffs(fields []Field) []fieldDiff {
+	rs := make([]fieldDiff, len(fields))
+	for i, f := range fields {
+		rs[i] = fieldDiff{Field: f, Differ: f.Release != "" && f.Test != "" && f.Release != f.Test}
+	}
+	return rs
+}
+
+// chart draws one line per node of the named series as inline svg.
+func chart(title string, nodes []Node, series string) template.HTML {
+	lines := make(map[string][]Point)
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		name := fmt.Sprintf("%s %s", node.Role, node.Version)
+		switch series {
+		case "supply":
+			lines[name] = node.Supply
+		case "intervals":
+			lines[name] = node.Intervals
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, points := range lines {
+		for _, p := range points {
+			minX, maxX = math.Min(minX, float64(p.Order)), math.Max(maxX, float64(p.Order))
+			minY, maxY = math.Min(minY, p.Value), math.Max(maxY, p.Value)
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return template.HTML(`<p style="color:#555">No data.</p>`)
+	}
+	if maxX == minX {
+		maxX++
+	}
+	if maxY == minY {
+		maxY++
+	}
+	x := func(v float64) float64 {
+		return chart_padding + (v-minX)/(maxX-minX)*(chart_width-2*chart_padding)
+	}
+	y := func(v float64) float64 {
+		return chart_height - chart_padding + (minY-v)/(maxY-minY)*(chart_height-2*chart_padding)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" style="font-family:Helvetica,Arial,sans-serif;font-size:11px">`,
+		chart_width, chart_height)
+	fmt.Fprintf(&b, `<text x="%d" y="14">%s</text>`, chart_padding, template.HTMLEscapeString(title))
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`,
+		chart_padding, chart_height-chart_padding, chart_width-chart_padding, chart_height-chart_padding)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`,
+		chart_padding, chart_padding, chart_padding, chart_height-chart_padding)
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%.0f</text>`, chart_padding, chart_height-chart_padding+14, minX)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%.0f</text>`, chart_width-chart_padding, chart_height-chart_padding+14, maxX)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%.4g</text>`, chart_padding-2, chart_padding+4, maxY)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%.4g</text>`, chart_padding-2, chart_height-chart_padding, minY)
+	for i, name := range names {
+		color := chart_colors[i%len(chart_colors)]
+		coords := make([]string, 0, len(lines[name]))
+		for _, p := range lines[name] {
+			coords = append(coords, fmt.Sprintf("%.1f,%.1f", x(float64(p.Order)), y(p.Value)))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`, color, strings.Join(coords, " "))
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`,
+			chart_width-chart_padding-200, 14+i*14, color, template.HTMLEscapeString(name))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
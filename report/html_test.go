@@ -0,0 +1,58 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	bytes, err := testReport().HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(bytes)
+	for _, want := range []string{
+		"Qitmeer test report #7",
+		"orders 10 to 20",
+		"New findings (1)",
+		"Resolved findings (1)",
+		"fees, other findings (2)",
+		// messages are escaped
+		"fee &lt;differs&gt;",
+		// the differing field is marked, the equal one is not
+		`<tr style="background:#fdd"><td style="padding:2px 8px">fee</td>`,
+		`<tr><td style="padding:2px 8px">size</td>`,
+		"<polyline",
+		"No data.",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html misses %q", want)
+		}
+	}
+	if strings.Contains(html, "pow, other findings") {
+		t.Error("section of a validator without findings")
+	}
+}
+
+func TestSection(t *testing.T) {
+	findings := make([]Finding, 0, section_findings+5)
+	for i := 0; i < section_findings+5; i++ {
+		findings = append(findings, Finding{ID: fmt.Sprint(i), Validator: "fees", Status: "recurring"})
+	}
+	findings = append(findings, Finding{Validator: "fees", Status: "new"}, Finding{Validator: "pow", Status: "recurring"})
+	s := section(findings, "fees")
+	if s.Total != section_findings+5 || len(s.Findings) != section_findings || s.More != 5 {
+		t.Fatalf("section total %d listed %d more %d", s.Total, len(s.Findings), s.More)
+	}
+	if section(findings[len(findings)-2:len(findings)-1], "fees") != nil {
+		t.Fatal("section of new findings only")
+	}
+}
+
+func TestDiffs(t *testing.T) {
+	d := diffs([]Field{{Name: "a", Release: "1", Test: "2"}, {Name: "b", Release: "1", Test: "1"}, {Name: "c", Release: "1"}})
+	if !d[0].Differ || d[1].Differ || d[2].Differ {
+		t.Fatalf("diffs %+v", d)
+	}
+}
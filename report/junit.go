@@ -0,0 +1,145 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+)
+
+// The JUnit report maps every validator to a test suite. Its first case
+// covers the checked order range and fails when the validator found
+// anything not acknowledged, every finding is a failed case of its own and
+// acknowledged ones are skipped.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     int64        `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func (r *Report) JUnit() ([]byte, error) {
+	suites := junitSuites{Name: "qitmeer", Time: r.Run.Seconds}
+	properties := make([]junitProperty, 0, len(r.Nodes))
+	for _, node := range r.Nodes {
+		properties = append(properties, junitProperty{Name: node.Role, Value: node.Version})
+	}
+
+	for _, v := range r.Validators {
+		suite := junitSuite{
+			Name:       v.Name,
+			Timestamp:  r.Run.Start.Format("2006-01-02T15:04:05"),
+			Properties: properties,
+		}
+		class := "qitmeer." + v.Name
+		rangeCase := junitCase{
+			Name:      fmt.Sprintf("orders %d-%d", r.Run.FirstOrder, r.Run.LastOrder),
+			ClassName: class,
+		}
+		suite.Cases = append(suite.Cases, rangeCase)
+
+		failed := 0
+		for _, f := range r.Findings {
+			if f.Validator != v.Name {
+				continue
+			}
+			c := junitCase{Name: fmt.Sprintf("order %d %s [%s]", f.Order, f.Kind, f.ID), ClassName: class}
+			if f.Acked {
+				c.Skipped = &junitSkipped{Message: "acknowledged"}
+			} else {
+				c.Failure = &junitFailure{Message: f.Message, Type: f.Kind, Text: failureText(&f)}
+				failed++
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		if failed != 0 {
+			suite.Cases[0].Failure = &junitFailure{
+				Message: fmt.Sprintf("%d of %d checks failed", failed, v.Checked),
+				Type:    v.Name,
+			}
+		}
+
+		for _, c := range suite.Cases {
+			suite.Tests++
+			if c.Failure != nil {
+				suite.Failures++
+			}
+			if c.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	bytes, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), bytes...), nil
+}
+
+func failureText(f *Finding) string {
+	text := fmt.Sprintf("order=%d hash=%s status=%s\n", f.Order, f.Hash, f.Status)
+	for _, field := range f.Fields {
+		text += fmt.Sprintf("%s release=%s test=%s\n", field.Name, field.Release, field.Test)
+	}
+	return text
+}
+
+// WriteJUnit writes the JUnit XML report to dir and returns the path of the
+// file.
+func WriteJUnit(dir string, r *Report) (string, error) {
+	bytes, err := r.JUnit()
+	if err != nil {
+		return "", err
+	}
+	return write(dir, r.Name()+".xml", bytes)
+}
+
+// WriteJUnitFile writes the JUnit XML report to a fixed path, for CI jobs
+// that pick up the same file after every run.
+func WriteJUnitFile(path string, r *Report) error {
+	bytes, err := r.JUnit()
+	if err != nil {
+		return err
+	}
+	_, err = write(filepath.Dir(path), filepath.Base(path), bytes)
+	return err
+}
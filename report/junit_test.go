@@ -0,0 +1,64 @@
+package report
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJUnit(t *testing.T) {
+	bytes, err := testReport().JUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites junitSuites
+	if err := xml.Unmarshal(bytes, &suites); err != nil {
+		t.Fatal(err)
+	}
+	// fees: the range case and the findings not acknowledged fail, the
+	// acknowledged one is skipped. pow: only the range case, which passes.
+	if suites.Tests != 5 || suites.Failures != 3 || suites.Skipped != 1 || len(suites.Suites) != 2 {
+		t.Fatalf("suites tests %d failures %d skipped %d", suites.Tests, suites.Failures, suites.Skipped)
+	}
+	fees, pow := suites.Suites[0], suites.Suites[1]
+	if fees.Tests != 4 || fees.Failures != 3 || fees.Skipped != 1 {
+		t.Fatalf("fees tests %d failures %d skipped %d", fees.Tests, fees.Failures, fees.Skipped)
+	}
+	if fees.Cases[0].Name != "orders 10-20" || fees.Cases[0].Failure == nil || fees.Cases[0].Failure.Message != "2 of 11 checks failed" {
+		t.Fatalf("range case %+v", fees.Cases[0])
+	}
+	if f := fees.Cases[1].Failure; f == nil || f.Type != "release-fee" || f.Text != "order=12 hash=h12 status=new\nfee release=1 test=2\nsize release=3 test=3\n" {
+		t.Fatalf("finding case %+v", fees.Cases[1])
+	}
+	if fees.Cases[3].Skipped == nil || fees.Cases[3].Failure != nil {
+		t.Fatalf("acknowledged case %+v", fees.Cases[3])
+	}
+	if pow.Tests != 1 || pow.Failures != 0 || pow.Cases[0].Failure != nil {
+		t.Fatalf("pow %+v", pow)
+	}
+	if len(fees.Properties) != 2 || fees.Properties[1].Name != "test" || fees.Properties[1].Value != "0.10.2" {
+		t.Fatalf("properties %+v", fees.Properties)
+	}
+}
+
+func TestWriteJUnitFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ci", "junit.xml")
+	if err := WriteJUnitFile(path, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(bytes, &junitSuites{}); err != nil {
+		t.Fatalf("written file, err=%s", err.Error())
+	}
+}
@@ -0,0 +1,135 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Report is the result of one run in a form scripts and dashboards can
+// read instead of the email text.
+type Report struct {
+	Run        Run         `json:"run"`
+	Nodes      []Node      `json:"nodes"`
+	Validators []Validator `json:"validators"`
+	Findings   []Finding   `json:"findings"`
+	Resolved   []Finding   `json:"resolved"`
+}
+
+type Run struct {
+	ID         uint64    `json:"id"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Seconds    int64     `json:"seconds"`
+	FirstOrder uint64    `json:"firstOrder"`
+	LastOrder  uint64    `json:"lastOrder"`
+	Snapshot   *Snapshot `json:"snapshot,omitempty"`
+	Canceled   bool      `json:"canceled,omitempty"`
+}
+
+// Snapshot is the utxo snapshot a run was seeded with.
+type Snapshot struct {
+	Order  uint64 `json:"order"`
+	Sha256 string `json:"sha256"`
+}
+
+// Node is what one node, release or test, looked like over the run. Rates
+// are blocks per second, the series are sampled down to a bounded number
+// of points.
+type Node struct {
+	Role       string             `json:"role"`
+	Version    string             `json:"version"`
+	Blocks     uint64             `json:"blocks"`
+	Utxo       uint64             `json:"utxo"`
+	FetchRate  float64            `json:"fetchRate"`
+	VerifyRate float64            `json:"verifyRate"`
+	Supply     []Point            `json:"supply"`
+	Intervals  []Point            `json:"intervals"`
+	Difficulty map[string][]Point `json:"difficulty"`
+}
+
+type Point struct {
+	Order uint64  `json:"order"`
+	Value float64 `json:"value"`
+}
+
+type Validator struct {
+	Name     string            `json:"name"`
+	Checked  uint64            `json:"checked"`
+	Failed   uint64            `json:"failed"`
+	Counters map[string]uint64 `json:"counters,omitempty"`
+	// Note tells what the validator did not check, like the retarget
+	Note string `json:"note,omitempty"`
+}
+
+// Finding is a failed verification, Status is new, recurring or resolved
+// when the run was recorded in the history.
+type Finding struct {
+	ID        string  `json:"id"`
+	Validator string  `json:"validator"`
+	Kind      string  `json:"kind"`
+	Order     uint64  `json:"order"`
+	Hash      string  `json:"hash"`
+	Message   string  `json:"message"`
+	Fields    []Field `json:"fields,omitempty"`
+	Status    string  `json:"status,omitempty"`
+	Acked     bool    `json:"acked,omitempty"`
+	FirstRun  uint64  `json:"firstRun,omitempty"`
+}
+
+// Field is a value behind a finding on the release and test nodes.
+type Field struct {
+	Name    string `json:"name"`
+	Release string `json:"release"`
+	Test    string `json:"test"`
+}
+
+// Sample keeps at most max of the points, evenly spaced and always the
+// last one.
+func Sample(points []Point, max int) []Point {
+	if max <= 0 || len(points) <= max {
+		return points
+	}
+	step := (len(points) + max - 1) / max
+	sampled := make([]Point, 0, max+1)
+	for i := 0; i < len(points); i += step {
+		sampled = append(sampled, points[i])
+	}
+	if last := points[len(points)-1]; sampled[len(sampled)-1] != last {
+		sampled = append(sampled, last)
+	}
+	return sampled
+}
+
+// Name is the file name of the report of a run without extension.
+func (r *Report) Name() string {
+	return fmt.Sprintf("report-%d-%s", r.Run.ID, r.Run.Start.Format("20060102-150405"))
+}
+
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// WriteJSON writes the report to dir and returns the path of the file.
+func WriteJSON(dir string, r *Report) (string, error) {
+	bytes, err := r.JSON()
+	if err != nil {
+		return "", err
+	}
+	return write(dir, r.Name()+".json", bytes)
+}
+
+// write replaces the file atomically, so readers never see half a report.
+func write(dir, name string, bytes []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path+".tmp", bytes, 0644); err != nil {
+		return "", err
+	}
+	return path, os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,77 @@
+package report
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testReport has a finding of every status, one of them acknowledged.
+func testReport() *Report {
+	return &Report{
+		Run: Run{ID: 7, Start: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Seconds: 60, FirstOrder: 10, LastOrder: 20},
+		Nodes: []Node{
+			{Role: "release", Version: "0.10.1", Supply: []Point{{Order: 10, Value: 1}, {Order: 20, Value: 2}}},
+			{Role: "test", Version: "0.10.2"},
+		},
+		Validators: []Validator{
+			{Name: "fees", Checked: 11, Failed: 2},
+			{Name: "pow", Checked: 11},
+		},
+		Findings: []Finding{
+			{ID: "a", Validator: "fees", Kind: "release-fee", Order: 12, Hash: "h12", Message: "fee <differs>", Status: "new",
+				Fields: []Field{{Name: "fee", Release: "1", Test: "2"}, {Name: "size", Release: "3", Test: "3"}}},
+			{ID: "b", Validator: "fees", Kind: "hash", Order: 13, Hash: "h13", Message: "hash differs", Status: "recurring"},
+			{ID: "c", Validator: "fees", Kind: "fee", Order: 14, Hash: "h14", Message: "acked", Status: "recurring", Acked: true},
+		},
+		Resolved: []Finding{{ID: "d", Validator: "pow", Kind: "pow", Order: 9, Message: "pow fixed", Status: "resolved"}},
+	}
+}
+
+func TestSample(t *testing.T) {
+	points := make([]Point, 10)
+	for i := range points {
+		points[i] = Point{Order: uint64(i)}
+	}
+	sampled := Sample(points, 4)
+	if len(sampled) > 5 || sampled[0].Order != 0 || sampled[len(sampled)-1].Order != 9 {
+		t.Fatalf("sampled %v", sampled)
+	}
+	if len(Sample(points, 0)) != 10 || len(Sample(points, 10)) != 10 {
+		t.Fatal("sampled without a bound")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := testReport()
+	path, err := WriteJSON(filepath.Join(dir, "reports"), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "report-7-20200102-030405.json" {
+		t.Fatalf("path %s", path)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left, err=%v", err)
+	}
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read Report
+	if err := json.Unmarshal(bytes, &read); err != nil {
+		t.Fatal(err)
+	}
+	if read.Run.LastOrder != 20 || len(read.Findings) != 3 || read.Findings[0].Fields[0].Test != "2" || !read.Findings[2].Acked {
+		t.Fatalf("read %+v", read)
+	}
+}
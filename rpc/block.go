@@ -26,6 +26,7 @@ type Block struct {
 	ParentHash    []string      `json:"parents"`
 	ChildrenHash  []string      `json:"children"`
 	Pow           *Pow          `json:"pow"`
+	Header        string        `json:"-"`
 }
 
 type Pow struct {
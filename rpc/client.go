@@ -6,16 +6,20 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/bCoder778/log"
+	"github.com/bCoder778/qitmeer_test/metrics"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type RpcAuth struct {
 	Host string `toml:"host"`
 	User string `toml:"user"`
 	Pwd  string `toml:"pwd"`
+	// Name tells the nodes apart in the metrics
+	Name string `toml:"-"`
 }
 
 type Client struct {
@@ -26,6 +30,10 @@ func NewClient(auth *RpcAuth) *Client {
 	return &Client{auth}
 }
 
+func (c *Client) Name() string {
+	return c.rpcAuth.Name
+}
+
 func (c *Client) GetBlock(h uint64) (*Block, bool) {
 	params := []interface{}{h, true}
 	resp := NewReqeust(params).SetMethod("getBlockByOrder").call(c.rpcAuth)
@@ -53,6 +61,19 @@ func (c *Client) GetBlockByHash(hash string) (*Block, bool) {
 	return blk, true
 }
 
+func (c *Client) GetBlockHeader(hash string) (string, error) {
+	params := []interface{}{hash, false}
+	resp := NewReqeust(params).SetMethod("getBlockHeader").call(c.rpcAuth)
+	if resp.Error != nil {
+		return "", errors.New(resp.Error.Message)
+	}
+	var header string
+	if err := json.Unmarshal(resp.Result, &header); err != nil {
+		return "", err
+	}
+	return header, nil
+}
+
 func (c *Client) GetBlockCount() string {
 	var params []interface{}
 	resp := NewReqeust(params).SetMethod("getBlockCount").call(c.rpcAuth)
@@ -189,7 +210,18 @@ func (c *Client) GetFees(hash string) (uint64, error) {
 	return strconv.ParseUint(string(resp.Result), 10, 64)
 }
 
+// call sends the request and records its duration and failure.
 func (req *ClientRequest) call(auth *RpcAuth) *ClientResponse {
+	start := time.Now()
+	resp := req.send(auth)
+	metrics.RPCDuration.Observe(time.Since(start).Seconds(), req.Method, auth.Name)
+	if resp.Error != nil {
+		metrics.RPCErrors.Inc(req.Method, auth.Name)
+	}
+	return resp
+}
+
+func (req *ClientRequest) send(auth *RpcAuth) *ClientResponse {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
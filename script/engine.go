@@ -0,0 +1,293 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const max_multisig_keys = 20
+
+var (
+	ErrEvalFalse      = errors.New("script evaluated to false")
+	ErrVerify         = errors.New("verify failed")
+	ErrEarlyReturn    = errors.New("script returned early")
+	ErrStackUnderflow = errors.New("stack underflow")
+	ErrNotPushOnly    = errors.New("signature script is not push only")
+)
+
+// Engine executes a signature script followed by the public key script it
+// spends. Only the opcodes of the standard templates are supported.
+type Engine struct {
+	tx        *Tx
+	idx       int
+	sigScript []byte
+	pkScript  []byte
+	stack     [][]byte
+}
+
+func NewEngine(sigScript, pkScript []byte, tx *Tx, idx int) *Engine {
+	return &Engine{tx: tx, idx: idx, sigScript: sigScript, pkScript: pkScript}
+}
+
+func (e *Engine) Execute() error {
+	sigOps, err := Parse(e.sigScript)
+	if err != nil {
+		return fmt.Errorf("parse signature script failed, %s", err.Error())
+	}
+	for _, op := range sigOps {
+		if !op.IsPush() {
+			return ErrNotPushOnly
+		}
+	}
+	pkOps, err := Parse(e.pkScript)
+	if err != nil {
+		return fmt.Errorf("parse pubkey script failed, %s", err.Error())
+	}
+	if err := e.run(sigOps); err != nil {
+		return err
+	}
+	if err := e.run(pkOps); err != nil {
+		return err
+	}
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return ErrEvalFalse
+	}
+	return nil
+}
+
+func (e *Engine) run(ops []Opcode) error {
+	for i := range ops {
+		if err := e.step(&ops[i]); err != nil {
+			return fmt.Errorf("opcode %d (0x%02x) %s", i, ops[i].Op, err.Error())
+		}
+	}
+	return nil
+}
+
+func (e *Engine) step(op *Opcode) error {
+	if n, ok := op.SmallInt(); ok {
+		if n == 0 {
+			e.push(nil)
+		} else {
+			e.push([]byte{byte(n)})
+		}
+		return nil
+	}
+	switch {
+	case op.Op >= OP_DATA_1 && op.Op <= OP_PUSHDATA4:
+		e.push(op.Data)
+		return nil
+	case op.Op == OP_1NEGATE:
+		e.push([]byte{0x81})
+		return nil
+	}
+
+	switch op.Op {
+	case OP_NOP, OP_CODESEPARATOR, OP_CHECKLOCKTIMEVERIFY, OP_CHECKSEQUENCEVERIFY:
+		// Lock times are enforced by the node, here only the signatures matter
+		return nil
+	case OP_RETURN:
+		return ErrEarlyReturn
+	case OP_VERIFY:
+		return e.verify()
+	case OP_DROP:
+		_, err := e.pop()
+		return err
+	case OP_DUP:
+		v, err := e.peek()
+		if err != nil {
+			return err
+		}
+		e.push(v)
+		return nil
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.pushBool(bytes.Equal(a, b))
+		if op.Op == OP_EQUALVERIFY {
+			return e.verify()
+		}
+		return nil
+	case OP_HASH160:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(Hash160(v))
+		return nil
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.pushBool(e.checkSig(sig, pubKey))
+		if op.Op == OP_CHECKSIGVERIFY {
+			return e.verify()
+		}
+		return nil
+	case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+		ok, err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		e.pushBool(ok)
+		if op.Op == OP_CHECKMULTISIGVERIFY {
+			return e.verify()
+		}
+		return nil
+	}
+	return errors.New("unsupported opcode")
+}
+
+// checkMultiSig pops the keys and signatures, unlike bitcoin there is no
+// extra dummy element.
+func (e *Engine) checkMultiSig() (bool, error) {
+	keyCount, err := e.popInt()
+	if err != nil {
+		return false, err
+	}
+	if keyCount < 0 || keyCount > max_multisig_keys {
+		return false, fmt.Errorf("invalid pubkey count %d", keyCount)
+	}
+	pubKeys := make([][]byte, keyCount)
+	for i := range pubKeys {
+		if pubKeys[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+	sigCount, err := e.popInt()
+	if err != nil {
+		return false, err
+	}
+	if sigCount < 0 || sigCount > keyCount {
+		return false, fmt.Errorf("invalid signature count %d", sigCount)
+	}
+	sigs := make([][]byte, sigCount)
+	for i := range sigs {
+		if sigs[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+	// Keys and signatures were popped in reverse, they must match in order
+	k := 0
+	for _, sig := range sigs {
+		for k < len(pubKeys) && !e.checkSig(sig, pubKeys[k]) {
+			k++
+		}
+		if k == len(pubKeys) {
+			return false, nil
+		}
+		k++
+	}
+	return true, nil
+}
+
+func (e *Engine) checkSig(sig, pubKey []byte) bool {
+	if len(sig) < 1 {
+		return false
+	}
+	hashType := sig[len(sig)-1]
+	signature, err := ecdsa.ParseDERSignature(sig[:len(sig)-1])
+	if err != nil {
+		return false
+	}
+	key, err := secp256k1.ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+	hash, err := e.tx.SignatureHash(e.pkScript, hashType, e.idx)
+	if err != nil {
+		return false
+	}
+	return signature.Verify(hash, key)
+}
+
+func (e *Engine) push(v []byte) {
+	e.stack = append(e.stack, v)
+}
+
+func (e *Engine) pushBool(v bool) {
+	if v {
+		e.push([]byte{1})
+	} else {
+		e.push(nil)
+	}
+}
+
+func (e *Engine) peek() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	return e.stack[len(e.stack)-1], nil
+}
+
+func (e *Engine) pop() ([]byte, error) {
+	v, err := e.peek()
+	if err != nil {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return v, nil
+}
+
+func (e *Engine) popInt() (int, error) {
+	v, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	if len(v) == 0 {
+		return 0, nil
+	}
+	if len(v) > 1 || v[0]&0x80 != 0 {
+		return 0, fmt.Errorf("unsupported number %x", v)
+	}
+	return int(v[0]), nil
+}
+
+func (e *Engine) verify() error {
+	v, err := e.pop()
+	if err != nil {
+		return err
+	}
+	if !asBool(v) {
+		return ErrVerify
+	}
+	return nil
+}
+
+func asBool(v []byte) bool {
+	for i := range v {
+		if v[i] != 0 {
+			// Negative zero is false
+			if i == len(v)-1 && v[i] == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Hash160 is ripemd160(blake2b256(b)), the hash used for qitmeer addresses.
+func Hash160(b []byte) []byte {
+	h := blake2b.Sum256(b)
+	r := ripemd160.New()
+	r.Write(h[:])
+	return r.Sum(nil)
+}
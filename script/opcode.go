@@ -0,0 +1,100 @@
+package script
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	OP_0                   = 0x00
+	OP_DATA_1              = 0x01
+	OP_DATA_20             = 0x14
+	OP_DATA_33             = 0x21
+	OP_DATA_65             = 0x41
+	OP_DATA_75             = 0x4b
+	OP_PUSHDATA1           = 0x4c
+	OP_PUSHDATA2           = 0x4d
+	OP_PUSHDATA4           = 0x4e
+	OP_1NEGATE             = 0x4f
+	OP_1                   = 0x51
+	OP_16                  = 0x60
+	OP_NOP                 = 0x61
+	OP_VERIFY              = 0x69
+	OP_RETURN              = 0x6a
+	OP_DROP                = 0x75
+	OP_DUP                 = 0x76
+	OP_EQUAL               = 0x87
+	OP_EQUALVERIFY         = 0x88
+	OP_HASH160             = 0xa9
+	OP_CODESEPARATOR       = 0xab
+	OP_CHECKSIG            = 0xac
+	OP_CHECKSIGVERIFY      = 0xad
+	OP_CHECKMULTISIG       = 0xae
+	OP_CHECKMULTISIGVERIFY = 0xaf
+	OP_CHECKLOCKTIMEVERIFY = 0xb1
+	OP_CHECKSEQUENCEVERIFY = 0xb2
+)
+
+var ErrMalformedPush = errors.New("malformed push")
+
+// Opcode is one parsed instruction of a script, Data is set for pushes.
+type Opcode struct {
+	Op   byte
+	Data []byte
+}
+
+func (o *Opcode) IsPush() bool {
+	return o.Op <= OP_16 && o.Op != 0x50
+}
+
+// SmallInt returns the value of OP_0 and OP_1 through OP_16.
+func (o *Opcode) SmallInt() (int, bool) {
+	if o.Op == OP_0 {
+		return 0, true
+	}
+	if o.Op >= OP_1 && o.Op <= OP_16 {
+		return int(o.Op-OP_1) + 1, true
+	}
+	return 0, false
+}
+
+func Parse(script []byte) ([]Opcode, error) {
+	ops := make([]Opcode, 0, len(script))
+	for i := 0; i < len(script); {
+		op := script[i]
+		i++
+		var size int
+		switch {
+		case op >= OP_DATA_1 && op <= OP_DATA_75:
+			size = int(op)
+		case op == OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(script[i])
+			i++
+		case op == OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		case op == OP_PUSHDATA4:
+			if i+4 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint32(script[i:]))
+			i += 4
+		default:
+			ops = append(ops, Opcode{Op: op})
+			continue
+		}
+		if size < 0 || i+size > len(script) {
+			return nil, fmt.Errorf("%s, need %d bytes at %d", ErrMalformedPush.Error(), size, i)
+		}
+		ops = append(ops, Opcode{Op: op, Data: script[i : i+size]})
+		i += size
+	}
+	return ops, nil
+}
@@ -0,0 +1,184 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+func testTx() *Tx {
+	tx := &Tx{Version: 1, LockTime: 7, Expire: 8, Timestamp: 1600000000}
+	for i := 0; i < 3; i++ {
+		in := &TxIn{PrevIndex: uint32(i), Sequence: 0xffffffff, SignScript: []byte{OP_1}}
+		in.PrevHash[0] = byte(i + 1)
+		tx.TxIn = append(tx.TxIn, in)
+		tx.TxOut = append(tx.TxOut, &TxOut{Amount: uint64(i+1) * 1e8, PkScript: []byte{OP_1, byte(i)}})
+	}
+	return tx
+}
+
+// serialize writes the tx as rpc returns it, with its witness.
+func serialize(tx *Tx) string {
+	var w bytes.Buffer
+	writeUint32(&w, uint32(tx.Version))
+	writeVarInt(&w, uint64(len(tx.TxIn)))
+	for _, in := range tx.TxIn {
+		w.Write(in.PrevHash[:])
+		writeUint32(&w, in.PrevIndex)
+		writeUint32(&w, in.Sequence)
+	}
+	writeVarInt(&w, uint64(len(tx.TxOut)))
+	for _, out := range tx.TxOut {
+		writeUint64(&w, out.Amount)
+		writeVarBytes(&w, out.PkScript)
+	}
+	writeUint32(&w, tx.LockTime)
+	writeUint32(&w, tx.Expire)
+	writeUint32(&w, tx.Timestamp)
+	writeVarInt(&w, uint64(len(tx.TxIn)))
+	for _, in := range tx.TxIn {
+		writeVarBytes(&w, in.SignScript)
+	}
+	return hex.EncodeToString(w.Bytes())
+}
+
+func TestParseTx(t *testing.T) {
+	want := testTx()
+	raw := serialize(want)
+	tx, err := ParseTx(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serialize(tx) != raw {
+		t.Fatalf("parsed %+v", tx)
+	}
+	for name, bad := range map[string]string{
+		"not hex":        "zz",
+		"truncated":      raw[:len(raw)-4],
+		"serialize type": "01000300" + raw[8:],
+	} {
+		if _, err := ParseTx(bad); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
+
+// TestSignatureHashCommits checks what each hash type signs: changing a
+// committed part changes the hash, changing anything else does not.
+func TestSignatureHashCommits(t *testing.T) {
+	const idx = 1
+	sub := []byte{OP_DUP}
+	mutations := map[string]func(tx *Tx){
+		"own output":     func(tx *Tx) { tx.TxOut[idx].Amount++ },
+		"other output":   func(tx *Tx) { tx.TxOut[0].Amount++ },
+		"later output":   func(tx *Tx) { tx.TxOut[2].PkScript = nil },
+		"added output":   func(tx *Tx) { tx.TxOut = append(tx.TxOut, &TxOut{}) },
+		"other input":    func(tx *Tx) { tx.TxIn[0].PrevHash[1] = 9 },
+		"other sequence": func(tx *Tx) { tx.TxIn[2].Sequence = 1 },
+		"own sequence":   func(tx *Tx) { tx.TxIn[idx].Sequence = 1 },
+		"sign scripts":   func(tx *Tx) { tx.TxIn[0].SignScript = []byte{OP_0} },
+		"lock time":      func(tx *Tx) { tx.LockTime++ },
+	}
+	tests := []struct {
+		hashType  byte
+		unchanged []string
+	}{
+		{hashType: SigHashAll, unchanged: []string{"sign scripts"}},
+		{hashType: SigHashNone, unchanged: []string{"sign scripts", "own output", "other output", "later output", "added output", "other sequence"}},
+		{hashType: SigHashSingle, unchanged: []string{"sign scripts", "other output", "later output", "added output", "other sequence"}},
+		{hashType: SigHashAll | SigHashAnyOneCanPay, unchanged: []string{"sign scripts", "other input", "other sequence"}},
+		{hashType: SigHashNone | SigHashAnyOneCanPay, unchanged: []string{"sign scripts", "own output", "other output", "later output", "added output", "other input", "other sequence"}},
+	}
+	for _, test := range tests {
+		unchanged := make(map[string]bool)
+		for _, name := range test.unchanged {
+			unchanged[name] = true
+		}
+		base, err := testTx().SignatureHash(sub, test.hashType, idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, mutate := range mutations {
+			tx := testTx()
+			mutate(tx)
+			hash, err := tx.SignatureHash(sub, test.hashType, idx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(hash, base) != unchanged[name] {
+				t.Errorf("hash type %02x, %s: unchanged=%v", test.hashType, name, bytes.Equal(hash, base))
+			}
+		}
+		if other, _ := testTx().SignatureHash([]byte{OP_DROP}, test.hashType, idx); bytes.Equal(other, base) {
+			t.Errorf("hash type %02x does not commit to the sub script", test.hashType)
+		}
+		if other, _ := testTx().SignatureHash(sub, test.hashType, 0); bytes.Equal(other, base) {
+			t.Errorf("hash type %02x does not commit to the input", test.hashType)
+		}
+	}
+	// bits outside the mask select nothing but are still committed
+	all, _ := testTx().SignatureHash(sub, SigHashAll, idx)
+	flagged, _ := testTx().SignatureHash(sub, SigHashAll|0x20, idx)
+	if bytes.Equal(all, flagged) {
+		t.Error("the hash type is not committed")
+	}
+}
+
+func TestSignatureHashErrors(t *testing.T) {
+	tx := testTx()
+	if _, err := tx.SignatureHash(nil, SigHashAll, 3); err == nil {
+		t.Error("input out of range")
+	}
+	tx.TxOut = tx.TxOut[:1]
+	if _, err := tx.SignatureHash(nil, SigHashSingle, 1); err == nil {
+		t.Error("sighash single without an output")
+	}
+}
+
+func TestEnginePayToPubKeyHash(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(bytes.Repeat([]byte{7}, 32))
+	pub := key.PubKey().SerializeCompressed()
+	pkScript := append([]byte{OP_DUP, OP_HASH160, OP_DATA_20}, Hash160(pub)...)
+	pkScript = append(pkScript, OP_EQUALVERIFY, OP_CHECKSIG)
+	if class := GetScriptClass(pkScript); class.String() != "pubkeyhash" {
+		t.Fatalf("class %s", class.String())
+	}
+
+	sign := func(tx *Tx, hashType byte, k *secp256k1.PrivateKey) []byte {
+		hash, err := tx.SignatureHash(pkScript, hashType, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := append(ecdsa.Sign(k, hash).Serialize(), hashType)
+		script := append([]byte{byte(len(sig))}, sig...)
+		return append(append(script, OP_DATA_33), pub...)
+	}
+	other := secp256k1.PrivKeyFromBytes(bytes.Repeat([]byte{8}, 32))
+	tests := []struct {
+		name     string
+		hashType byte
+		key      *secp256k1.PrivateKey
+		mutate   func(tx *Tx)
+		ok       bool
+	}{
+		{name: "all", hashType: SigHashAll, key: key, ok: true},
+		{name: "all, output changed", hashType: SigHashAll, key: key, mutate: func(tx *Tx) { tx.TxOut[0].Amount++ }},
+		{name: "none, output changed", hashType: SigHashNone, key: key, mutate: func(tx *Tx) { tx.TxOut[0].Amount++ }, ok: true},
+		{name: "single, own output changed", hashType: SigHashSingle, key: key, mutate: func(tx *Tx) { tx.TxOut[1].Amount++ }},
+		{name: "other key", hashType: SigHashAll, key: other},
+	}
+	for _, test := range tests {
+		tx := testTx()
+		sigScript := sign(tx, test.hashType, test.key)
+		if test.mutate != nil {
+			test.mutate(tx)
+		}
+		err := NewEngine(sigScript, pkScript, tx, 1).Execute()
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
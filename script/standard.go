@@ -0,0 +1,79 @@
+package script
+
+type ScriptClass int
+
+const (
+	NonStandardTy ScriptClass = iota
+	PubKeyTy
+	PubKeyHashTy
+	MultiSigTy
+	NullDataTy
+)
+
+func (c ScriptClass) String() string {
+	switch c {
+	case PubKeyTy:
+		return "pubkey"
+	case PubKeyHashTy:
+		return "pubkeyhash"
+	case MultiSigTy:
+		return "multisig"
+	case NullDataTy:
+		return "nulldata"
+	}
+	return "nonstandard"
+}
+
+// GetScriptClass recognizes the standard output templates.
+func GetScriptClass(script []byte) ScriptClass {
+	ops, err := Parse(script)
+	if err != nil {
+		return NonStandardTy
+	}
+	switch {
+	case isPubKey(ops):
+		return PubKeyTy
+	case isPubKeyHash(ops):
+		return PubKeyHashTy
+	case isMultiSig(ops):
+		return MultiSigTy
+	case len(ops) > 0 && ops[0].Op == OP_RETURN:
+		return NullDataTy
+	}
+	return NonStandardTy
+}
+
+func isPubKey(ops []Opcode) bool {
+	return len(ops) == 2 &&
+		(len(ops[0].Data) == 33 || len(ops[0].Data) == 65) &&
+		ops[1].Op == OP_CHECKSIG
+}
+
+func isPubKeyHash(ops []Opcode) bool {
+	return len(ops) == 5 &&
+		ops[0].Op == OP_DUP &&
+		ops[1].Op == OP_HASH160 &&
+		ops[2].Op == OP_DATA_20 &&
+		ops[3].Op == OP_EQUALVERIFY &&
+		ops[4].Op == OP_CHECKSIG
+}
+
+func isMultiSig(ops []Opcode) bool {
+	if len(ops) < 4 || ops[len(ops)-1].Op != OP_CHECKMULTISIG {
+		return false
+	}
+	required, ok := ops[0].SmallInt()
+	if !ok {
+		return false
+	}
+	keys, ok := ops[len(ops)-2].SmallInt()
+	if !ok || keys != len(ops)-3 || required == 0 || required > keys {
+		return false
+	}
+	for _, op := range ops[1 : len(ops)-2] {
+		if len(op.Data) != 33 && len(op.Data) != 65 {
+			return false
+		}
+	}
+	return true
+}
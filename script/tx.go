@@ -0,0 +1,279 @@
+package script
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	SigHashAll          = 0x1
+	SigHashNone         = 0x2
+	SigHashSingle       = 0x3
+	SigHashAnyOneCanPay = 0x80
+
+	sighash_mask = 0x1f
+)
+
+const (
+	txSerializeFull = iota
+	txSerializeNoWitness
+	txSerializeOnlyWitness
+	txSerializeWitnessSigning
+)
+
+type TxIn struct {
+	PrevHash   [32]byte
+	PrevIndex  uint32
+	Sequence   uint32
+	SignScript []byte
+}
+
+type TxOut struct {
+	Amount   uint64
+	PkScript []byte
+}
+
+// Tx is the wire form of a qitmeer transaction as far as signature hashing
+// needs it.
+type Tx struct {
+	Version   uint16
+	TxIn      []*TxIn
+	TxOut     []*TxOut
+	LockTime  uint32
+	Expire    uint32
+	Timestamp uint32
+}
+
+// ParseTx decodes the hex of a full transaction returned by rpc.
+func ParseTx(s string) (*Tx, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx hex, %s", err.Error())
+	}
+	r := &reader{buf: raw}
+	version := r.uint32()
+	tx := &Tx{Version: uint16(version)}
+	serType := version >> 16
+	if serType != txSerializeFull && serType != txSerializeNoWitness {
+		return nil, fmt.Errorf("unsupported tx serialize type %d", serType)
+	}
+
+	count := r.varInt()
+	if count > uint64(len(raw)) {
+		return nil, fmt.Errorf("too many tx inputs %d", count)
+	}
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		in := &TxIn{}
+		copy(in.PrevHash[:], r.bytes(32))
+		in.PrevIndex = r.uint32()
+		in.Sequence = r.uint32()
+		tx.TxIn = append(tx.TxIn, in)
+	}
+	count = r.varInt()
+	if count > uint64(len(raw)) {
+		return nil, fmt.Errorf("too many tx outputs %d", count)
+	}
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		out := &TxOut{}
+		out.Amount = r.uint64()
+		out.PkScript = r.varBytes()
+		tx.TxOut = append(tx.TxOut, out)
+	}
+	tx.LockTime = r.uint32()
+	tx.Expire = r.uint32()
+	tx.Timestamp = r.uint32()
+
+	if serType == txSerializeFull {
+		count = r.varInt()
+		if r.err == nil && count != uint64(len(tx.TxIn)) {
+			return nil, fmt.Errorf("witness count %d, inputs %d", count, len(tx.TxIn))
+		}
+		for _, in := range tx.TxIn {
+			in.SignScript = r.varBytes()
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("decode tx failed, %s", r.err.Error())
+	}
+	return tx, nil
+}
+
+// SignatureHash computes the message signed by input idx: the blake2b hash
+// of the hash type, the hash of the modified prefix and the hash of the
+// witness where only input idx carries the sub script.
+func (tx *Tx) SignatureHash(subScript []byte, hashType byte, idx int) ([]byte, error) {
+	if idx < 0 || idx >= len(tx.TxIn) {
+		return nil, fmt.Errorf("input index %d out of range", idx)
+	}
+	if hashType&sighash_mask == SigHashSingle && idx >= len(tx.TxOut) {
+		return nil, errors.New("sighash single without a matching output")
+	}
+
+	ins := make([]*TxIn, 0, len(tx.TxIn))
+	signIdx := idx
+	if hashType&SigHashAnyOneCanPay != 0 {
+		ins = append(ins, tx.TxIn[idx])
+		signIdx = 0
+	} else {
+		ins = append(ins, tx.TxIn...)
+	}
+	outs := tx.TxOut
+	zeroSequence := false
+	switch hashType & sighash_mask {
+	case SigHashNone:
+		outs = nil
+		zeroSequence = true
+	case SigHashSingle:
+		outs = make([]*TxOut, idx+1)
+		for i := 0; i < idx; i++ {
+			outs[i] = &TxOut{Amount: ^uint64(0)}
+		}
+		outs[idx] = tx.TxOut[idx]
+		zeroSequence = true
+	}
+
+	var prefix bytes.Buffer
+	writeUint32(&prefix, uint32(tx.Version)|txSerializeNoWitness<<16)
+	writeVarInt(&prefix, uint64(len(ins)))
+	for i, in := range ins {
+		prefix.Write(in.PrevHash[:])
+		writeUint32(&prefix, in.PrevIndex)
+		if zeroSequence && i != signIdx {
+			writeUint32(&prefix, 0)
+		} else {
+			writeUint32(&prefix, in.Sequence)
+		}
+	}
+	writeVarInt(&prefix, uint64(len(outs)))
+	for _, out := range outs {
+		writeUint64(&prefix, out.Amount)
+		writeVarBytes(&prefix, out.PkScript)
+	}
+	writeUint32(&prefix, tx.LockTime)
+	writeUint32(&prefix, tx.Expire)
+	writeUint32(&prefix, tx.Timestamp)
+
+	var witness bytes.Buffer
+	writeUint32(&witness, uint32(tx.Version)|txSerializeWitnessSigning<<16)
+	writeVarInt(&witness, uint64(len(ins)))
+	for i := range ins {
+		if i == signIdx {
+			writeVarBytes(&witness, subScript)
+		} else {
+			writeVarBytes(&witness, nil)
+		}
+	}
+
+	prefixHash := blake2b.Sum256(prefix.Bytes())
+	witnessHash := blake2b.Sum256(witness.Bytes())
+	var buf bytes.Buffer
+	writeUint32(&buf, uint32(hashType))
+	buf.Write(prefixHash[:])
+	buf.Write(witnessHash[:])
+	h := blake2b.Sum256(buf.Bytes())
+	return h[:], nil
+}
+
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = fmt.Errorf("unexpected end of data at %d, need %d bytes", r.pos, n)
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) uint64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (r *reader) varInt() uint64 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	switch b[0] {
+	case 0xfd:
+		if b = r.bytes(2); b != nil {
+			return uint64(binary.LittleEndian.Uint16(b))
+		}
+	case 0xfe:
+		return uint64(r.uint32())
+	case 0xff:
+		return r.uint64()
+	default:
+		return uint64(b[0])
+	}
+	return 0
+}
+
+func (r *reader) varBytes() []byte {
+	n := r.varInt()
+	if n > uint64(len(r.buf)) {
+		r.err = fmt.Errorf("var bytes length %d too big", n)
+		return nil
+	}
+	return r.bytes(int(n))
+}
+
+func writeUint32(w *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
+
+func writeUint64(w *bytes.Buffer, v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	w.Write(b[:])
+}
+
+func writeVarInt(w *bytes.Buffer, v uint64) {
+	switch {
+	case v < 0xfd:
+		w.WriteByte(byte(v))
+	case v <= 0xffff:
+		w.WriteByte(0xfd)
+		var b [2]byte
+		binary.LittleEndian.PutUint16(b[:], uint16(v))
+		w.Write(b[:])
+	case v <= 0xffffffff:
+		w.WriteByte(0xfe)
+		writeUint32(w, uint32(v))
+	default:
+		w.WriteByte(0xff)
+		writeUint64(w, v)
+	}
+}
+
+func writeVarBytes(w *bytes.Buffer, b []byte) {
+	writeVarInt(w, uint64(len(b)))
+	w.Write(b)
+}
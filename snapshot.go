@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/check/check_db"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/db/base"
+)
+
+var (
+	exportPath  = flag.String("export", "", "export the utxo snapshot of -db to this file and exit")
+	exportDB    = flag.String("db", "release_db", "check database to export the snapshot from")
+	exportOrder = flag.Int64("order", -1, "order of the exported snapshot, -1 for the last verified order")
+	inspectPath = flag.String("inspect", "", "verify the utxo snapshot file, print what it holds and exit")
+)
+
+// snapshotCommand runs the snapshot command given on the command line, ok
+// is false when there is none.
+func snapshotCommand() (ok bool, err error) {
+	var info *check_db.SnapshotInfo
+	switch {
+	case *exportPath != "":
+		// A memory database is gone with the run that filled it
+		if conf.Setting.Backend == base.BackendMemory {
+			return true, fmt.Errorf("can not export a snapshot with the memory backend, nothing is kept after a run")
+		}
+		db, err := check_db.NewCheckDB(conf.Setting.Backend, *exportDB)
+		if err != nil {
+			return true, err
+		}
+		defer db.Close()
+		order := uint64(*exportOrder)
+		if *exportOrder < 0 {
+			last, ok := db.LastOrder()
+			if !ok {
+				return true, fmt.Errorf("%s has no verified blocks to export", *exportDB)
+			}
+			order = last
+		}
+		if info, err = db.ExportSnapshot(*exportPath, order); err != nil {
+			return true, fmt.Errorf("export snapshot failed, %s", err.Error())
+		}
+		fmt.Printf("Exported %s to %s\n", *exportDB, *exportPath)
+	case *inspectPath != "":
+		if info, err = check_db.ReadSnapshot(*inspectPath, nil); err != nil {
+			return true, err
+		}
+	default:
+		return false, nil
+	}
+	fmt.Printf("order=%d, utxo=%d, total=%d, sha256=%s\n", info.Order, info.Stats.Count, info.Stats.Total, info.Sum)
+	return true, nil
+}
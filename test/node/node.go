@@ -1,8 +1,7 @@
 package node
 
 import (
-	"fmt"
-	"github.com/bCoder778/log"
+	"github.com/bCoder778/qitmeer_test/progress"
 	"github.com/bCoder778/qitmeer_test/rpc"
 	"strconv"
 	"time"
@@ -18,38 +17,58 @@ func New(client *rpc.Client) *Node {
 	return &Node{client: client, version: info.Buildversion}
 }
 
-func (n *Node) Sync(lastOrder uint64) chan *rpc.Block {
+// Sync sends the blocks of the tracked orders once they are confirmed, it
+// gives up when stop is closed.
+func (n *Node) Sync(stop <-chan bool, tracker *progress.Tracker) chan *rpc.Block {
 	blocks := make(chan *rpc.Block, 100)
-	var start uint64
+	status := tracker.Status()
+	start, lastOrder := status.FirstOrder, status.LastOrder
 	go func() {
+		defer close(blocks)
 		for start <= lastOrder {
+			select {
+			case <-stop:
+				return
+			default:
+			}
 
 			block, ok := n.client.GetBlock(start)
 			if !ok {
-				time.Sleep(time.Second * 10)
+				wait(stop, time.Second*10)
 			} else {
 				if block.Confirmations > 720 {
 					color, err := n.client.IsBlue(block.Hash)
 					if err != nil {
-						time.Sleep(time.Second * 10)
+						wait(stop, time.Second*10)
+					} else if block.Header, err = n.client.GetBlockHeader(block.Hash); err != nil {
+						wait(stop, time.Second*10)
 					} else {
-						if start%(lastOrder/10) == 0 {
-							log.Mail(fmt.Sprintf("Test %s qitmeer progress %.2f %%", n.version, float64(start*100)/float64(lastOrder)))
-						}
 						block.IsBlue = color
-						blocks <- block
+						select {
+						case blocks <- block:
+						case <-stop:
+							return
+						}
+						tracker.Fetched(block.Order)
 						start++
 					}
 				} else {
-					time.Sleep(time.Second * 10)
+					wait(stop, time.Second*10)
 				}
 			}
 		}
-		close(blocks)
 	}()
 	return blocks
 }
 
+// wait sleeps for d or until stop is closed.
+func wait(stop <-chan bool, d time.Duration) {
+	select {
+	case <-stop:
+	case <-time.After(d):
+	}
+}
+
 func (n *Node) BlockCount() uint64 {
 	count := n.client.GetBlockCount()
 	iCount, _ := strconv.ParseUint(count, 10, 64)
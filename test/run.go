@@ -0,0 +1,176 @@
+package test
+
+import (
+	"errors"
+	"github.com/bCoder778/log"
+	"github.com/bCoder778/qitmeer_test/check"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/history"
+	"github.com/bCoder778/qitmeer_test/progress"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRunning = errors.New("a run is in progress")
+	ErrIdle    = errors.New("no run is in progress")
+)
+
+// Progress is how far the current run got, Order is the last verified
+// order and LastOrder the one the run stops at.
+type Progress struct {
+	Running        bool              `json:"running"`
+	Canceled       bool              `json:"canceled,omitempty"`
+	Start          int64             `json:"start,omitempty"`
+	ReleaseVersion string            `json:"releaseVersion,omitempty"`
+	TestVersion    string            `json:"testVersion,omitempty"`
+	FirstOrder     uint64            `json:"firstOrder"`
+	LastOrder      uint64            `json:"lastOrder"`
+	Order          uint64            `json:"order"`
+	Findings       int               `json:"findings"`
+	Nodes          []progress.Status `json:"nodes,omitempty"`
+}
+
+// Only one run at a time, the check databases are not shared.
+var current struct {
+	sync.Mutex
+	running    bool
+	canceled   bool
+	start      int64
+	check      *check.Check
+	trackers   []*progress.Tracker
+	releaseVer string
+	testVer    string
+	first      uint64
+	last       uint64
+	// done is closed when the run ends
+	done chan struct{}
+}
+
+var histories sync.Mutex
+
+// TestQitmeer runs a comparison and returns when it is done, it is skipped
+// when another run is in progress.
+func TestQitmeer() {
+	if err := begin(); err != nil {
+		log.Infof("Skip qitmeer test, %s", err.Error())
+		return
+	}
+	defer end()
+	testQitmeer()
+}
+
+// Start runs a comparison in the background.
+func Start() error {
+	if err := begin(); err != nil {
+		return err
+	}
+	go func() {
+		defer end()
+		testQitmeer()
+	}()
+	return nil
+}
+
+// Cancel stops the current run before its next block, what was verified
+// until then is still recorded and reported.
+func Cancel() error {
+	current.Lock()
+	defer current.Unlock()
+	if !current.running {
+		return ErrIdle
+	}
+	current.canceled = true
+	if current.check != nil {
+		current.check.Stop()
+	}
+	return nil
+}
+
+func CurrentProgress() Progress {
+	current.Lock()
+	defer current.Unlock()
+	p := Progress{
+		Running:        current.running,
+		Canceled:       current.canceled,
+		Start:          current.start,
+		ReleaseVersion: current.releaseVer,
+		TestVersion:    current.testVer,
+		FirstOrder:     current.first,
+		LastOrder:      current.last,
+	}
+	if current.check != nil {
+		p.Order, p.Findings = current.check.Progress()
+	}
+	for _, t := range current.trackers {
+		p.Nodes = append(p.Nodes, t.Status())
+	}
+	return p
+}
+
+func begin() error {
+	current.Lock()
+	defer current.Unlock()
+	if current.running {
+		return ErrRunning
+	}
+	current.running, current.canceled, current.check, current.trackers = true, false, nil, nil
+	current.done = make(chan struct{})
+	current.start = time.Now().Unix()
+	current.releaseVer, current.testVer, current.first, current.last = "", "", 0, 0
+	return nil
+}
+
+func end() {
+	current.Lock()
+	defer current.Unlock()
+	current.running = false
+	close(current.done)
+}
+
+// Wait returns once the current run, started by the timer or the api,
+// ended.
+func Wait() {
+	current.Lock()
+	done := current.done
+	current.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// started makes the check of the run visible to Progress and Cancel, a
+// cancel that came before it stops it right away.
+func started(c *check.Check, releaseVer, testVer string, first, last uint64, trackers ...*progress.Tracker) {
+	current.Lock()
+	defer current.Unlock()
+	current.check, current.trackers = c, trackers
+	current.releaseVer, current.testVer = releaseVer, testVer
+	current.first, current.last = first, last
+	if current.canceled {
+		c.Stop()
+	}
+}
+
+// History is the history database opened by OpenHistory.
+type History struct {
+	*history.History
+}
+
+// OpenHistory opens the history database, the caller must Close it as soon
+// as it is done. It is not kept open so the command line can use it while
+// the tester runs, and the users in the tester wait for each other.
+func OpenHistory() (*History, error) {
+	histories.Lock()
+	h, err := history.Open(conf.Setting.History.Path)
+	if err != nil {
+		histories.Unlock()
+		return nil, err
+	}
+	return &History{History: h}, nil
+}
+
+func (h *History) Close() {
+	h.History.Close()
+	histories.Unlock()
+}
@@ -4,6 +4,11 @@ import (
 	"github.com/bCoder778/log"
 	"github.com/bCoder778/qitmeer_test/check"
 	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/history"
+	"github.com/bCoder778/qitmeer_test/metrics"
+	"github.com/bCoder778/qitmeer_test/notify"
+	"github.com/bCoder778/qitmeer_test/progress"
+	"github.com/bCoder778/qitmeer_test/report"
 	"github.com/bCoder778/qitmeer_test/rpc"
 	"github.com/bCoder778/qitmeer_test/test/node"
 )
@@ -11,33 +16,115 @@ import (
 var Release *node.Node
 var Test *node.Node
 
-func TestQitmeer() {
-	Release = node.New(rpc.NewClient(&rpc.RpcAuth{
-		Host: conf.Setting.ReleaseNode.Host,
-		User: conf.Setting.ReleaseNode.User,
-		Pwd:  conf.Setting.ReleaseNode.Pass,
-	}))
-
-	Test = node.New(rpc.NewClient(&rpc.RpcAuth{
-		Host: conf.Setting.TestNode.Host,
-		User: conf.Setting.TestNode.User,
-		Pwd:  conf.Setting.TestNode.Pass,
-	}))
+func testQitmeer() {
+	Release = node.New(NewClient(&conf.Setting.ReleaseNode, "release"))
+	Test = node.New(NewClient(&conf.Setting.TestNode, "test"))
 
 	log.Infof("Start qitmeer test, release=%s, test=%s", Release.Version(), Test.Version())
 	order := conf.Setting.Order
 	if order == 0 {
 		order = Release.BlockCount()
 	}
-	reBlocks := Release.Sync(order)
-	tsBlocks := Test.Sync(order)
 
 	validators, err := check.New(Release.Version(), Test.Version())
 	if err != nil {
 		log.Errorf("Failed to create check.err=%s", err.Error())
 		return
 	}
+	if h, err := OpenHistory(); err != nil {
+		log.Errorf("Failed to open history %s, err=%s", conf.Setting.History.Path, err.Error())
+	} else {
+		if known, err := h.Known(); err != nil {
+			log.Errorf("Failed to load known findings, err=%s", err.Error())
+		} else {
+			validators.SetKnown(known)
+		}
+		h.Close()
+	}
+
+	start := validators.StartOrder()
+	releaseTrack, testTrack := progress.New("release", start, order), progress.New("test", start, order)
+	validators.SetTrackers(releaseTrack, testTrack)
+	started(validators, Release.Version(), Test.Version(), start, order, releaseTrack, testTrack)
+	reBlocks := Release.Sync(validators.Done(), releaseTrack)
+	tsBlocks := Test.Sync(validators.Done(), testTrack)
 	validators.CheckNode(reBlocks, tsBlocks)
+	if validators.Stopped() {
+		order, _ := validators.Progress()
+		log.Infof("Qitmeer test canceled after order %d", order)
+	}
+	log.Infof("%s", releaseTrack.Status().String())
+	log.Infof("%s", testTrack.Status().String())
 	validators.Close()
-	log.Mail("Test Qitmeer Report", validators.SendReport())
+	notify.EndRun()
+	run := validators.Run()
+	metrics.Runs.Inc()
+	metrics.LastRunDuration.Set(float64(run.End - run.Start))
+	metrics.LastRunTimestamp.Set(float64(run.End))
+	changes := saveRun(run)
+	r := validators.Report(run, changes)
+	writeReport(r)
+	body, err := r.HTML()
+	if err != nil {
+		log.Errorf("Failed to render html report, err=%s", err.Error())
+		body = []byte(validators.SendReport(changes))
+	}
+	notify.Send(&notify.Message{Severity: notify.Notice, Subject: "Test Qitmeer Report", Body: string(body), HTML: err == nil})
+}
+
+// NewClient connects to a node of the config, name is release or test.
+func NewClient(n *conf.Node, name string) *rpc.Client {
+	return rpc.NewClient(&rpc.RpcAuth{
+		Host: n.Host,
+		User: n.User,
+		Pwd:  n.Pass,
+		Name: name,
+	})
+}
+
+// writeReport saves the reports of the run for scripts and dashboards.
+// Every format is written on its own, one that fails does not keep the
+// others from being written.
+func writeReport(r *report.Report) {
+	writers := []struct {
+		format string
+		write  func(dir string, r *report.Report) (string, error)
+	}{
+		{"json", report.WriteJSON},
+		{"html", report.WriteHTML},
+		{"junit", report.WriteJUnit},
+	}
+	for _, w := range writers {
+		path, err := w.write(conf.Setting.Report.Dir, r)
+		if err != nil {
+			log.Errorf("Failed to write %s report, err=%s", w.format, err.Error())
+			continue
+		}
+		log.Infof("Write %s report %s", w.format, path)
+	}
+	if conf.Setting.Report.JUnit != "" {
+		if err := report.WriteJUnitFile(conf.Setting.Report.JUnit, r); err != nil {
+			log.Errorf("Failed to write junit report %s, err=%s", conf.Setting.Report.JUnit, err.Error())
+		}
+	}
+}
+
+// saveRun records the run in the history database and returns how it
+// changed the issues, nil when there is no history. A failure is logged but
+// does not stop the report.
+func saveRun(run *history.Run) *history.Changes {
+	h, err := OpenHistory()
+	if err != nil {
+		log.Errorf("Failed to open history %s, err=%s", conf.Setting.History.Path, err.Error())
+		return nil
+	}
+	defer h.Close()
+	changes, err := h.Add(run)
+	if err != nil {
+		log.Errorf("Failed to save run, err=%s", err.Error())
+		return nil
+	}
+	log.Infof("Save run %d to history, new=%d, recurring=%d, resolved=%d",
+		run.ID, len(changes.New), len(changes.Recurring), len(changes.Resolved))
+	return changes
 }
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bCoder778/qitmeer_test/conf"
+	"github.com/bCoder778/qitmeer_test/report"
+)
+
+func TestWriteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(setting conf.Report) { conf.Setting.Report = setting }(conf.Setting.Report)
+	conf.Setting.Report = conf.Report{Dir: filepath.Join(dir, "reports"), JUnit: filepath.Join(dir, "ci", "junit.xml")}
+
+	r := &report.Report{Run: report.Run{ID: 3, Start: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}}
+	// A directory in the way of the json report fails its rename, the
+	// other formats are still written
+	if err := os.MkdirAll(filepath.Join(conf.Setting.Report.Dir, r.Name()+".json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeReport(r)
+
+	for _, path := range []string{
+		filepath.Join(conf.Setting.Report.Dir, r.Name()+".html"),
+		filepath.Join(conf.Setting.Report.Dir, r.Name()+".xml"),
+		conf.Setting.Report.JUnit,
+	} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("%s not written, err=%v", path, err)
+		}
+	}
+}
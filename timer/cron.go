@@ -0,0 +1,193 @@
+package timer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron_years bounds the search for the next time, a schedule that does not
+// fire within it, like the 30th of February, never fires.
+const cron_years = 5
+
+var cron_macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cron_months = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var cron_days = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Cron is a standard five field schedule, minute hour day-of-month month
+// day-of-week, in a timezone. Fields take *, numbers, names of months and
+// days, ranges, lists and steps. As in cron, when both day fields are
+// restricted a day matching either of them fires.
+type Cron struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// domAll and dowAll are set for a day field starting with *
+	domAll bool
+	dowAll bool
+	loc    *time.Location
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var cron_fields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: cron_months},
+	{name: "day of week", min: 0, max: 7, names: cron_days},
+}
+
+func ParseCron(expr string, loc *time.Location) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cron_macros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(cron_fields) {
+		return nil, fmt.Errorf("cron %q must have %d fields, got %d", expr, len(cron_fields), len(parts))
+	}
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		var err error
+		if bits[i], err = cron_fields[i].parse(part); err != nil {
+			return nil, fmt.Errorf("cron %q, %s", expr, err.Error())
+		}
+	}
+	// Sunday is 0 and 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &Cron{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAll: strings.HasPrefix(parts[2], "*"),
+		dowAll: strings.HasPrefix(parts[4], "*"),
+		loc:    loc,
+	}, nil
+}
+
+// parse turns a field into a bit set of the values it matches.
+func (f *cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step in %s %q", f.name, item)
+			}
+			rng = item[:i]
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			var err error
+			if lo, err = f.value(rng[:i]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(rng[i+1:]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("bad range in %s %q", f.name, item)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			// a/n is a to the maximum every n
+			if step == 1 {
+				hi = lo
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f *cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("bad %s %q, must be %d to %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next is the first minute after the given time the schedule matches. A
+// wall clock time repeated when daylight saving ends fires once.
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	last := wall(after.In(c.loc))
+	limit := t.Year() + cron_years
+	for t.Year() <= limit {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<uint(m)) == 0:
+			t = forward(t, time.Date(y, m+1, 1, 0, 0, 0, 0, c.loc))
+		case !c.matchDay(t):
+			t = forward(t, time.Date(y, m, d+1, 0, 0, 0, 0, c.loc))
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = forward(t, time.Date(y, m, d, t.Hour()+1, 0, 0, 0, c.loc))
+		case c.minute&(1<<uint(t.Minute())) == 0 || !wall(t).After(last):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *Cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAll || c.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// wall is the wall clock time of t to the minute, without the zone.
+func wall(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// forward moves to next, unless a daylight saving change makes next not
+// later than t, then it moves a minute.
+func forward(t, next time.Time) time.Time {
+	if !next.After(t) {
+		return t.Add(time.Minute)
+	}
+	return next
+}
@@ -0,0 +1,110 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	after := time.Date(2021, 1, 15, 10, 30, 20, 0, time.UTC) // a Friday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2021, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2021, 1, 16, 10, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2021, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2021, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0,12 * * *", time.Date(2021, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon-wed", time.Date(2021, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * MAR *", time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{"0 0 20 * sat", time.Date(2021, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 20 * *", time.Date(2021, 1, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 */5 * sat", time.Date(2021, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2021, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"@Monthly", time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		c, err := ParseCron(test.expr, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if got := c.Next(after); !got.Equal(test.want) {
+			t.Errorf("%s: next %v, want %v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * foo *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"@never",
+	} {
+		if _, err := ParseCron(expr, time.UTC); err == nil {
+			t.Errorf("%q parsed", expr)
+		}
+	}
+}
+
+func TestCronZone(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	c, err := ParseCron("0 9 * * *", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.Next(time.Date(2021, 1, 15, 2, 0, 0, 0, time.UTC))
+	if want := time.Date(2021, 1, 16, 1, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("next %v, want %v", got, want)
+	}
+}
+
+func TestCronDaylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		// 2:30 does not exist on the 14th of March
+		{"30 2 * * *", time.Date(2021, 3, 14, 0, 0, 0, 0, loc), time.Date(2021, 3, 15, 2, 30, 0, 0, loc)},
+		{"0 3 * * *", time.Date(2021, 3, 14, 0, 0, 0, 0, loc), time.Date(2021, 3, 14, 3, 0, 0, 0, loc)},
+		// 1:30 happens twice on the 7th of November and fires once
+		{"30 1 * * *", time.Date(2021, 11, 7, 0, 0, 0, 0, loc), time.Date(2021, 11, 7, 5, 30, 0, 0, time.UTC)},
+		{"30 1 * * *", time.Date(2021, 11, 7, 5, 30, 0, 0, time.UTC), time.Date(2021, 11, 8, 1, 30, 0, 0, loc)},
+		{"*/30 * * * *", time.Date(2021, 11, 7, 5, 30, 0, 0, time.UTC), time.Date(2021, 11, 7, 7, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		c, err := ParseCron(test.expr, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Next(test.after); !got.Equal(test.want) {
+			t.Errorf("%s after %v: next %v, want %v", test.expr, test.after, got, test.want)
+		}
+	}
+}
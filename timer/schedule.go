@@ -0,0 +1,43 @@
+package timer
+
+import (
+	"fmt"
+	"github.com/bCoder778/qitmeer_test/conf"
+	"time"
+)
+
+// Schedule tells when to fire next, strictly after the given time. The
+// zero time means never again.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Every fires every interval from start.
+type Every struct {
+	Start    time.Time
+	Interval time.Duration
+}
+
+func (e *Every) Next(after time.Time) time.Time {
+	if after.Before(e.Start) {
+		return e.Start
+	}
+	n := after.Sub(e.Start)/e.Interval + 1
+	return e.Start.Add(n * e.Interval)
+}
+
+// NewSchedule builds the schedule of the task, cron when it is set and a
+// fixed interval otherwise.
+func NewSchedule(task *conf.Task) (Schedule, error) {
+	loc := task.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	if task.Cron != "" {
+		return ParseCron(task.Cron, loc)
+	}
+	if task.Interval <= 0 {
+		return nil, fmt.Errorf("task interval must be positive, got %d", task.Interval)
+	}
+	return &Every{Start: time.Unix(task.Timestamp, 0).In(loc), Interval: time.Duration(task.Interval) * time.Second}, nil
+}
@@ -3,42 +3,83 @@ package timer
 import (
 	"fmt"
 	"github.com/bCoder778/log"
-	"sync"
+	"github.com/bCoder778/qitmeer_test/notify"
 	"time"
 )
 
+// What to do with the fire times that passed while f was running.
+const (
+	MissedSkip    = "skip"
+	MissedCatchUp = "catchup"
+)
+
 type Timer struct {
-	mutex   sync.RWMutex
-	stop    chan bool
-	stopped chan bool
+	schedule Schedule
+	missed   string
+	stop     chan bool
+	stopped  chan bool
+}
+
+func New(schedule Schedule, missed string) (*Timer, error) {
+	switch missed {
+	case "":
+		missed = MissedSkip
+	case MissedSkip, MissedCatchUp:
+	default:
+		return nil, fmt.Errorf("missed must be %s or %s, got %s", MissedSkip, MissedCatchUp, missed)
+	}
+	return &Timer{schedule: schedule, missed: missed, stop: make(chan bool), stopped: make(chan bool)}, nil
 }
 
-func New() *Timer {
-	return &Timer{stop: make(chan bool), stopped: make(chan bool)}
+// Start calls f at every fire time of the schedule in the background, it
+// sleeps until the next one instead of polling.
+func (t *Timer) Start(f func()) {
+	go t.loop(f)
 }
 
-func (t *Timer) Start(f func(), timestamp int64, interval int64) {
-	c := time.NewTicker(time.Millisecond * 1).C
+func (t *Timer) loop(f func()) {
+	defer close(t.stopped)
 	id := 1
+	next := t.schedule.Next(time.Now())
 	for {
+		if next.IsZero() {
+			log.Infof("Schedule has no more fire times")
+			<-t.stop
+			return
+		}
+		log.Infof("Next test func %d at %s", id, next.Format("2006-01-02 15:04:05 MST"))
+		wait := time.NewTimer(time.Until(next))
 		select {
-		case _, ok := <-t.stop:
-			if !ok {
-				log.Infof("Stop timer")
-				t.stopped <- true
-				return
-			}
-		case <-c:
-			if (time.Now().Unix()-timestamp)%interval == 0 {
-				log.Mail(fmt.Sprintf("Start test func %d", id), time.Now().String())
-				f()
-				id++
-				time.Sleep(time.Second)
-			}
+		case <-t.stop:
+			wait.Stop()
+			log.Infof("Stop timer")
+			return
+		case <-wait.C:
+		}
+
+		notify.Sendf(notify.Info, fmt.Sprintf("Start test func %d", id), time.Now().String())
+		f()
+		id++
+
+		now := time.Now()
+		missed := 0
+		for following := t.schedule.Next(next); !following.IsZero() && !following.After(now); following = t.schedule.Next(following) {
+			next = following
+			missed++
+		}
+		if missed != 0 && t.missed == MissedCatchUp {
+			log.Infof("Test func ran past %d fire times, catch up now", missed)
+			next = now
+			continue
+		}
+		if missed != 0 {
+			log.Infof("Test func ran past %d fire times, skip them", missed)
 		}
+		next = t.schedule.Next(now)
 	}
 }
 
+// Stop waits for the test func that is running to return.
 func (t *Timer) Stop() {
 	close(t.stop)
 	<-t.stopped